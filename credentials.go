@@ -0,0 +1,169 @@
+package oss
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+const (
+	// credentialProtocolECSRAMRole will fetch temporary credentials from ECS
+	// instance metadata, like `ecs_ram_role:<role_name>`. The RAM role attached
+	// to the instance will be detected if role name is empty.
+	credentialProtocolECSRAMRole = "ecs_ram_role"
+
+	// ecsRAMRoleEndpoint is the ECS instance metadata endpoint for RAM role credentials.
+	// ref: https://help.aliyun.com/document_detail/54579.html
+	ecsRAMRoleEndpoint = "http://100.100.100.200/latest/meta-data/ram/security-credentials/"
+	// ecsRAMRoleRefreshBefore is how long before the expiration we will refresh the credentials.
+	ecsRAMRoleRefreshBefore = 5 * time.Minute
+)
+
+// ecsRAMRoleCredentials is the temporary credentials returned by ECS instance metadata.
+type ecsRAMRoleCredentials struct {
+	Code            string    `json:"Code"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	AccessKeySecret string    `json:"AccessKeySecret"`
+	SecurityToken   string    `json:"SecurityToken"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// GetAccessKeyID implements oss.Credentials
+func (c *ecsRAMRoleCredentials) GetAccessKeyID() string {
+	return c.AccessKeyID
+}
+
+// GetAccessKeySecret implements oss.Credentials
+func (c *ecsRAMRoleCredentials) GetAccessKeySecret() string {
+	return c.AccessKeySecret
+}
+
+// GetSecurityToken implements oss.Credentials
+func (c *ecsRAMRoleCredentials) GetSecurityToken() string {
+	return c.SecurityToken
+}
+
+// ecsRAMRoleProvider will fetch temporary credentials from ECS instance metadata,
+// and refresh them before expiration.
+type ecsRAMRoleProvider struct {
+	client   *http.Client
+	endpoint string
+	roleName string
+
+	mu    sync.Mutex
+	creds *ecsRAMRoleCredentials
+	// refreshing will be true while the credentials are being refreshed, so
+	// that only one request will be sent.
+	refreshing bool
+}
+
+// newECSRAMRoleProvider will create a provider and fetch the credentials at once,
+// so that misconfiguration can be reported while creating the service.
+//
+// The RAM role attached to the instance will be used if roleName is empty.
+func newECSRAMRoleProvider(roleName string) (p *ecsRAMRoleProvider, err error) {
+	p = &ecsRAMRoleProvider{
+		client:   &http.Client{Timeout: 5 * time.Second},
+		endpoint: ecsRAMRoleEndpoint,
+		roleName: roleName,
+	}
+
+	if p.roleName == "" {
+		p.roleName, err = p.get(p.endpoint)
+		if err != nil {
+			return nil, err
+		}
+		p.roleName = strings.TrimSpace(p.roleName)
+	}
+
+	p.creds, err = p.fetch()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetCredentials implements oss.CredentialsProvider
+//
+// The credentials are refreshed without holding the lock, other requests will
+// keep using the old credentials during the refresh instead of being blocked.
+func (p *ecsRAMRoleProvider) GetCredentials() oss.Credentials {
+	p.mu.Lock()
+	creds := p.creds
+	if p.refreshing || time.Now().Add(ecsRAMRoleRefreshBefore).Before(creds.Expiration) {
+		p.mu.Unlock()
+		return creds
+	}
+	p.refreshing = true
+	p.mu.Unlock()
+
+	// GetCredentials can't return an error, keep using the old credentials
+	// and let OSS report the error if refresh failed.
+	refreshed, err := p.fetch()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.refreshing = false
+	if err == nil {
+		p.creds = refreshed
+	}
+	return p.creds
+}
+
+func (p *ecsRAMRoleProvider) fetch() (creds *ecsRAMRoleCredentials, err error) {
+	content, err := p.get(p.endpoint + p.roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	creds = &ecsRAMRoleCredentials{}
+	err = json.Unmarshal([]byte(content), creds)
+	if err != nil {
+		return nil, err
+	}
+	if creds.Code != "Success" {
+		return nil, fmt.Errorf("fetch credentials for ram role %s: %s", p.roleName, creds.Code)
+	}
+	return creds, nil
+}
+
+func (p *ecsRAMRoleProvider) get(url string) (string, error) {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return string(content), nil
+}
+
+// anonymousTransport will remove the signature added by OSS SDK, so that the
+// requests will be treated as anonymous by OSS.
+//
+// OSS SDK signs every request, and OSS will reject an invalid signature even
+// for public-read objects.
+type anonymousTransport struct {
+	rt http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t anonymousTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip should not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Del(oss.HTTPHeaderAuthorization)
+	return t.rt.RoundTrip(req)
+}
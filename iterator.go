@@ -1,17 +1,60 @@
 package oss
 
-import "strconv"
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	typ "github.com/beyondstorage/go-storage/v4/types"
+)
 
 type objectPageStatus struct {
-	delimiter    string
-	maxKeys      int
-	prefix       string
-	marker       string
-	partIdMarker string
+	delimiter       string
+	maxKeys         int
+	prefix          string
+	marker          string
+	partIdMarker    string
+	versionIdMarker string
+
+	// workDir is the key prefix of work dir, used to convert marker into path.
+	workDir string
+	// done will be true once all pages have been listed.
+	done bool
 }
 
+// ContinuationToken returns the path of the next marker, which can be passed
+// to list_start_after to resume the listing after the fetched pages.
+//
+// Empty string will be returned once the listing is not truncated anymore.
 func (i *objectPageStatus) ContinuationToken() string {
-	return i.marker
+	if i.done {
+		return ""
+	}
+	return strings.TrimPrefix(i.marker, i.workDir)
+}
+
+type parallelObjectPageStatus struct {
+	maxKeys     int
+	prefix      string
+	concurrency int
+
+	// pages receives the objects listed by workers, it will be closed once all
+	// prefixes have been listed or any of them failed.
+	pages chan parallelObjectPage
+	// cancel stops the workers, it will be called once the iteration is done
+	// or failed. Abandoned iterations must cancel the context passed to List.
+	cancel context.CancelFunc
+}
+
+// ContinuationToken returns empty string, because prefixes are listed
+// concurrently and the listing can't be resumed from a single marker.
+func (i *parallelObjectPageStatus) ContinuationToken() string {
+	return ""
+}
+
+type parallelObjectPage struct {
+	objects []*typ.Object
+	err     error
 }
 
 type storagePageStatus struct {
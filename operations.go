@@ -0,0 +1,1398 @@
+package oss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/beyondstorage/go-storage/v4/services"
+	. "github.com/beyondstorage/go-storage/v4/types"
+)
+
+// The operations in this file are specific to OSS and not defined by go-storage,
+// so they can't be generated from service.toml. They are written in the same
+// way as generated.go, with the implementations in service.go and storage.go.
+//
+// Operations which extend a generated operation, like WriteFile for write,
+// apply the default pairs of that operation and skip the unsupported ones.
+
+// DeleteLifecycle will delete all lifecycle rules of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) DeleteLifecycle(name string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.DeleteLifecycleWithContext(ctx, name, pairs...)
+}
+
+// DeleteLifecycleWithContext will delete all lifecycle rules of the bucket.
+func (s *Service) DeleteLifecycleWithContext(ctx context.Context, name string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("delete_lifecycle", err, name)
+	}()
+
+	// No pairs are supported by delete_lifecycle yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.deleteLifecycle(ctx, name)
+}
+
+// DeleteLogging will disable the access logging of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) DeleteLogging(name string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.DeleteLoggingWithContext(ctx, name, pairs...)
+}
+
+// DeleteLoggingWithContext will disable the access logging of the bucket.
+func (s *Service) DeleteLoggingWithContext(ctx context.Context, name string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("delete_logging", err, name)
+	}()
+
+	// No pairs are supported by delete_logging yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.deleteLogging(ctx, name)
+}
+
+// DeletePolicy will delete the policy of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) DeletePolicy(name string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.DeletePolicyWithContext(ctx, name, pairs...)
+}
+
+// DeletePolicyWithContext will delete the policy of the bucket.
+func (s *Service) DeletePolicyWithContext(ctx context.Context, name string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("delete_policy", err, name)
+	}()
+
+	// No pairs are supported by delete_policy yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.deletePolicy(ctx, name)
+}
+
+// DeleteWebsite will delete the static website hosting configuration of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) DeleteWebsite(name string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.DeleteWebsiteWithContext(ctx, name, pairs...)
+}
+
+// DeleteWebsiteWithContext will delete the static website hosting configuration of the bucket.
+func (s *Service) DeleteWebsiteWithContext(ctx context.Context, name string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("delete_website", err, name)
+	}()
+
+	// No pairs are supported by delete_website yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.deleteWebsite(ctx, name)
+}
+
+// GetBucketInfo will get the information of the bucket, like region, ACL and
+// versioning status.
+//
+// This function will create a context by default.
+func (s *Service) GetBucketInfo(name string, pairs ...Pair) (info *BucketInfo, err error) {
+	ctx := context.Background()
+	return s.GetBucketInfoWithContext(ctx, name, pairs...)
+}
+
+// GetBucketInfoWithContext will get the information of the bucket, like region,
+// ACL and versioning status.
+func (s *Service) GetBucketInfoWithContext(ctx context.Context, name string, pairs ...Pair) (info *BucketInfo, err error) {
+	defer func() {
+		err = s.formatError("get_bucket_info", err, name)
+	}()
+
+	// No pairs are supported by get_bucket_info yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.getBucketInfo(ctx, name)
+}
+
+// GetLifecycle will get the lifecycle rules of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) GetLifecycle(name string, pairs ...Pair) (rules []LifecycleRule, err error) {
+	ctx := context.Background()
+	return s.GetLifecycleWithContext(ctx, name, pairs...)
+}
+
+// GetLifecycleWithContext will get the lifecycle rules of the bucket.
+//
+// No error will be returned if the bucket doesn't have lifecycle rules, rules will be empty instead.
+func (s *Service) GetLifecycleWithContext(ctx context.Context, name string, pairs ...Pair) (rules []LifecycleRule, err error) {
+	defer func() {
+		err = s.formatError("get_lifecycle", err, name)
+	}()
+
+	// No pairs are supported by get_lifecycle yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.getLifecycle(ctx, name)
+}
+
+// GetLogging will get the access logging configuration of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) GetLogging(name string, pairs ...Pair) (cfg *LoggingConfig, err error) {
+	ctx := context.Background()
+	return s.GetLoggingWithContext(ctx, name, pairs...)
+}
+
+// GetLoggingWithContext will get the access logging configuration of the bucket.
+//
+// cfg will be nil if the access logging is disabled.
+func (s *Service) GetLoggingWithContext(ctx context.Context, name string, pairs ...Pair) (cfg *LoggingConfig, err error) {
+	defer func() {
+		err = s.formatError("get_logging", err, name)
+	}()
+
+	// No pairs are supported by get_logging yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.getLogging(ctx, name)
+}
+
+// GetPolicy will get the policy of the bucket in JSON.
+//
+// This function will create a context by default.
+func (s *Service) GetPolicy(name string, pairs ...Pair) (policy string, err error) {
+	ctx := context.Background()
+	return s.GetPolicyWithContext(ctx, name, pairs...)
+}
+
+// GetPolicyWithContext will get the policy of the bucket in JSON.
+//
+// No error will be returned if the bucket doesn't have a policy, policy will be empty instead.
+func (s *Service) GetPolicyWithContext(ctx context.Context, name string, pairs ...Pair) (policy string, err error) {
+	defer func() {
+		err = s.formatError("get_policy", err, name)
+	}()
+
+	// No pairs are supported by get_policy yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.getPolicy(ctx, name)
+}
+
+// GetReferer will get the referer whitelist configuration of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) GetReferer(name string, pairs ...Pair) (cfg *RefererConfig, err error) {
+	ctx := context.Background()
+	return s.GetRefererWithContext(ctx, name, pairs...)
+}
+
+// GetRefererWithContext will get the referer whitelist configuration of the bucket.
+func (s *Service) GetRefererWithContext(ctx context.Context, name string, pairs ...Pair) (cfg *RefererConfig, err error) {
+	defer func() {
+		err = s.formatError("get_referer", err, name)
+	}()
+
+	// No pairs are supported by get_referer yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.getReferer(ctx, name)
+}
+
+// GetWebsite will get the static website hosting configuration of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) GetWebsite(name string, pairs ...Pair) (cfg *WebsiteConfig, err error) {
+	ctx := context.Background()
+	return s.GetWebsiteWithContext(ctx, name, pairs...)
+}
+
+// GetWebsiteWithContext will get the static website hosting configuration of the bucket.
+//
+// A nil config will be returned if static website hosting is not enabled.
+func (s *Service) GetWebsiteWithContext(ctx context.Context, name string, pairs ...Pair) (cfg *WebsiteConfig, err error) {
+	defer func() {
+		err = s.formatError("get_website", err, name)
+	}()
+
+	// No pairs are supported by get_website yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.getWebsite(ctx, name)
+}
+
+// SetLifecycle will replace the lifecycle rules of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) SetLifecycle(name string, rules []LifecycleRule, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.SetLifecycleWithContext(ctx, name, rules, pairs...)
+}
+
+// SetLifecycleWithContext will replace the lifecycle rules of the bucket.
+func (s *Service) SetLifecycleWithContext(ctx context.Context, name string, rules []LifecycleRule, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("set_lifecycle", err, name)
+	}()
+
+	// No pairs are supported by set_lifecycle yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.setLifecycle(ctx, name, rules)
+}
+
+// SetLogging will enable the access logging of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) SetLogging(name string, cfg LoggingConfig, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.SetLoggingWithContext(ctx, name, cfg, pairs...)
+}
+
+// SetLoggingWithContext will enable the access logging of the bucket.
+//
+// ErrBucketNotExist will be returned if the target bucket doesn't exist, and
+// services.ErrRestrictionDissatisfied will be returned if the target bucket is
+// not in the same region as the bucket.
+func (s *Service) SetLoggingWithContext(ctx context.Context, name string, cfg LoggingConfig, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("set_logging", err, name)
+	}()
+
+	// No pairs are supported by set_logging yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.setLogging(ctx, name, cfg)
+}
+
+// SetPolicy will replace the policy of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) SetPolicy(name string, policy string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.SetPolicyWithContext(ctx, name, policy, pairs...)
+}
+
+// SetPolicyWithContext will replace the policy of the bucket.
+//
+// The policy is a RAM policy in JSON, it will be checked to be well-formed
+// before sending to OSS.
+//
+// ref: https://help.aliyun.com/document_detail/100680.html
+func (s *Service) SetPolicyWithContext(ctx context.Context, name string, policy string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("set_policy", err, name)
+	}()
+
+	// No pairs are supported by set_policy yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.setPolicy(ctx, name, policy)
+}
+
+// SetReferer will replace the referer whitelist configuration of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) SetReferer(name string, cfg RefererConfig, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.SetRefererWithContext(ctx, name, cfg, pairs...)
+}
+
+// SetRefererWithContext will replace the referer whitelist configuration of the bucket.
+//
+// The referers will be checked before sending to OSS, so that typos like
+// whitespaces and unsupported schemes can be caught.
+func (s *Service) SetRefererWithContext(ctx context.Context, name string, cfg RefererConfig, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("set_referer", err, name)
+	}()
+
+	// No pairs are supported by set_referer yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.setReferer(ctx, name, cfg)
+}
+
+// SetWebsite will replace the static website hosting configuration of the bucket.
+//
+// This function will create a context by default.
+func (s *Service) SetWebsite(name string, cfg WebsiteConfig, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.SetWebsiteWithContext(ctx, name, cfg, pairs...)
+}
+
+// SetWebsiteWithContext will replace the static website hosting configuration of the bucket.
+func (s *Service) SetWebsiteWithContext(ctx context.Context, name string, cfg WebsiteConfig, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("set_website", err, name)
+	}()
+
+	// No pairs are supported by set_website yet.
+	if len(pairs) > 0 {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.setWebsite(ctx, name, cfg)
+}
+
+// pairStorageDownloadFile is the parsed struct
+type pairStorageDownloadFile struct {
+	pairs            []Pair
+	HasCheckpointDir bool
+	CheckpointDir    string
+	HasConcurrency   bool
+	Concurrency      int
+	HasPartSize      bool
+	PartSize         int64
+	HasVersionID     bool
+	VersionID        string
+}
+
+// parsePairStorageDownloadFile will parse Pair slice into *pairStorageDownloadFile
+//
+// defaults are the default pairs of read, which are applied after opts.
+func (s *Storage) parsePairStorageDownloadFile(opts []Pair, defaults []Pair) (pairStorageDownloadFile, error) {
+	pairs := make([]Pair, 0, len(opts)+len(defaults))
+	pairs = append(append(pairs, opts...), defaults...)
+
+	result := pairStorageDownloadFile{
+		pairs: pairs,
+	}
+
+	for i, v := range pairs {
+		switch v.Key {
+		case "checkpoint_dir":
+			if result.HasCheckpointDir {
+				continue
+			}
+			result.HasCheckpointDir = true
+			result.CheckpointDir = v.Value.(string)
+			continue
+		case "concurrency":
+			if result.HasConcurrency {
+				continue
+			}
+			result.HasConcurrency = true
+			result.Concurrency = v.Value.(int)
+			continue
+		case "part_size":
+			if result.HasPartSize {
+				continue
+			}
+			result.HasPartSize = true
+			result.PartSize = v.Value.(int64)
+			continue
+		case "version_id":
+			if result.HasVersionID {
+				continue
+			}
+			result.HasVersionID = true
+			result.VersionID = v.Value.(string)
+			continue
+		default:
+			// The default pairs are shared with read, skip the ones not supported by download_file.
+			if i >= len(opts) {
+				continue
+			}
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageDownloadFile{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageExists is the parsed struct
+type pairStorageExists struct {
+	pairs        []Pair
+	HasVersionID bool
+	VersionID    string
+}
+
+// parsePairStorageExists will parse Pair slice into *pairStorageExists
+//
+// defaults are the default pairs of stat, which are applied after opts.
+func (s *Storage) parsePairStorageExists(opts []Pair, defaults []Pair) (pairStorageExists, error) {
+	pairs := make([]Pair, 0, len(opts)+len(defaults))
+	pairs = append(append(pairs, opts...), defaults...)
+
+	result := pairStorageExists{
+		pairs: pairs,
+	}
+
+	for i, v := range pairs {
+		switch v.Key {
+		case "version_id":
+			if result.HasVersionID {
+				continue
+			}
+			result.HasVersionID = true
+			result.VersionID = v.Value.(string)
+			continue
+		default:
+			// The default pairs are shared with stat, skip the ones not supported by exists.
+			if i >= len(opts) {
+				continue
+			}
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageExists{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageReadFile is the parsed struct
+type pairStorageReadFile struct {
+	pairs        []Pair
+	HasTempDir   bool
+	TempDir      string
+	HasVersionID bool
+	VersionID    string
+}
+
+// parsePairStorageReadFile will parse Pair slice into *pairStorageReadFile
+//
+// defaults are the default pairs of read, which are applied after opts.
+func (s *Storage) parsePairStorageReadFile(opts []Pair, defaults []Pair) (pairStorageReadFile, error) {
+	pairs := make([]Pair, 0, len(opts)+len(defaults))
+	pairs = append(append(pairs, opts...), defaults...)
+
+	result := pairStorageReadFile{
+		pairs: pairs,
+	}
+
+	for i, v := range pairs {
+		switch v.Key {
+		case "temp_dir":
+			if result.HasTempDir {
+				continue
+			}
+			result.HasTempDir = true
+			result.TempDir = v.Value.(string)
+			continue
+		case "version_id":
+			if result.HasVersionID {
+				continue
+			}
+			result.HasVersionID = true
+			result.VersionID = v.Value.(string)
+			continue
+		default:
+			// The default pairs are shared with read, skip the ones not supported by read_file.
+			if i >= len(opts) {
+				continue
+			}
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageReadFile{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageRestore is the parsed struct
+type pairStorageRestore struct {
+	pairs          []Pair
+	HasRestoreDays bool
+	RestoreDays    int
+	HasRestoreTier bool
+	RestoreTier    string
+}
+
+// parsePairStorageRestore will parse Pair slice into *pairStorageRestore
+func (s *Storage) parsePairStorageRestore(opts []Pair) (pairStorageRestore, error) {
+	result := pairStorageRestore{
+		pairs: opts,
+	}
+
+	for _, v := range opts {
+		switch v.Key {
+		case "restore_days":
+			if result.HasRestoreDays {
+				continue
+			}
+			result.HasRestoreDays = true
+			result.RestoreDays = v.Value.(int)
+			continue
+		case "restore_tier":
+			if result.HasRestoreTier {
+				continue
+			}
+			result.HasRestoreTier = true
+			result.RestoreTier = v.Value.(string)
+			continue
+		default:
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageRestore{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageSelectObject is the parsed struct
+type pairStorageSelectObject struct {
+	pairs                        []Pair
+	HasSelectCompressionType     bool
+	SelectCompressionType        string
+	HasSelectCsvFieldDelimiter   bool
+	SelectCsvFieldDelimiter      string
+	HasSelectCsvFileHeaderInfo   bool
+	SelectCsvFileHeaderInfo      string
+	HasSelectCsvRecordDelimiter  bool
+	SelectCsvRecordDelimiter     string
+	HasSelectJSONRecordDelimiter bool
+	SelectJSONRecordDelimiter    string
+	HasSelectJSONType            bool
+	SelectJSONType               string
+}
+
+// parsePairStorageSelectObject will parse Pair slice into *pairStorageSelectObject
+func (s *Storage) parsePairStorageSelectObject(opts []Pair) (pairStorageSelectObject, error) {
+	result := pairStorageSelectObject{
+		pairs: opts,
+	}
+
+	for _, v := range opts {
+		switch v.Key {
+		case "select_compression_type":
+			if result.HasSelectCompressionType {
+				continue
+			}
+			result.HasSelectCompressionType = true
+			result.SelectCompressionType = v.Value.(string)
+			continue
+		case "select_csv_field_delimiter":
+			if result.HasSelectCsvFieldDelimiter {
+				continue
+			}
+			result.HasSelectCsvFieldDelimiter = true
+			result.SelectCsvFieldDelimiter = v.Value.(string)
+			continue
+		case "select_csv_file_header_info":
+			if result.HasSelectCsvFileHeaderInfo {
+				continue
+			}
+			result.HasSelectCsvFileHeaderInfo = true
+			result.SelectCsvFileHeaderInfo = v.Value.(string)
+			continue
+		case "select_csv_record_delimiter":
+			if result.HasSelectCsvRecordDelimiter {
+				continue
+			}
+			result.HasSelectCsvRecordDelimiter = true
+			result.SelectCsvRecordDelimiter = v.Value.(string)
+			continue
+		case "select_json_record_delimiter":
+			if result.HasSelectJSONRecordDelimiter {
+				continue
+			}
+			result.HasSelectJSONRecordDelimiter = true
+			result.SelectJSONRecordDelimiter = v.Value.(string)
+			continue
+		case "select_json_type":
+			if result.HasSelectJSONType {
+				continue
+			}
+			result.HasSelectJSONType = true
+			result.SelectJSONType = v.Value.(string)
+			continue
+		default:
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageSelectObject{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageUpdateMeta is the parsed struct
+type pairStorageUpdateMeta struct {
+	pairs                                 []Pair
+	HasCacheControl                       bool
+	CacheControl                          string
+	HasContentDisposition                 bool
+	ContentDisposition                    string
+	HasContentEncoding                    bool
+	ContentEncoding                       string
+	HasContentType                        bool
+	ContentType                           string
+	HasExpires                            bool
+	Expires                               time.Time
+	HasServerSideEncryptionCustomerKey    bool
+	ServerSideEncryptionCustomerKey       []byte
+	HasServerSideEncryptionCustomerKeyMd5 bool
+	ServerSideEncryptionCustomerKeyMd5    string
+	HasUserMetadata                       bool
+	UserMetadata                          map[string]string
+}
+
+// parsePairStorageUpdateMeta will parse Pair slice into *pairStorageUpdateMeta
+func (s *Storage) parsePairStorageUpdateMeta(opts []Pair) (pairStorageUpdateMeta, error) {
+	result := pairStorageUpdateMeta{
+		pairs: opts,
+	}
+
+	for _, v := range opts {
+		switch v.Key {
+		case "cache_control":
+			if result.HasCacheControl {
+				continue
+			}
+			result.HasCacheControl = true
+			result.CacheControl = v.Value.(string)
+			continue
+		case "content_disposition":
+			if result.HasContentDisposition {
+				continue
+			}
+			result.HasContentDisposition = true
+			result.ContentDisposition = v.Value.(string)
+			continue
+		case "content_encoding":
+			if result.HasContentEncoding {
+				continue
+			}
+			result.HasContentEncoding = true
+			result.ContentEncoding = v.Value.(string)
+			continue
+		case "content_type":
+			if result.HasContentType {
+				continue
+			}
+			result.HasContentType = true
+			result.ContentType = v.Value.(string)
+			continue
+		case "expires":
+			if result.HasExpires {
+				continue
+			}
+			result.HasExpires = true
+			result.Expires = v.Value.(time.Time)
+			continue
+		case "server_side_encryption_customer_key":
+			if result.HasServerSideEncryptionCustomerKey {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKey = true
+			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
+			continue
+		case "server_side_encryption_customer_key_md5":
+			if result.HasServerSideEncryptionCustomerKeyMd5 {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKeyMd5 = true
+			result.ServerSideEncryptionCustomerKeyMd5 = v.Value.(string)
+			continue
+		case "user_metadata":
+			if result.HasUserMetadata {
+				continue
+			}
+			result.HasUserMetadata = true
+			result.UserMetadata = v.Value.(map[string]string)
+			continue
+		default:
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageUpdateMeta{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageUploadFile is the parsed struct
+type pairStorageUploadFile struct {
+	pairs            []Pair
+	HasCheckpointDir bool
+	CheckpointDir    string
+	HasConcurrency   bool
+	Concurrency      int
+	HasContentType   bool
+	ContentType      string
+	HasPartSize      bool
+	PartSize         int64
+	HasStorageClass  bool
+	StorageClass     string
+}
+
+// parsePairStorageUploadFile will parse Pair slice into *pairStorageUploadFile
+//
+// defaults are the default pairs of create_multipart, which are applied after opts.
+func (s *Storage) parsePairStorageUploadFile(opts []Pair, defaults []Pair) (pairStorageUploadFile, error) {
+	pairs := make([]Pair, 0, len(opts)+len(defaults))
+	pairs = append(append(pairs, opts...), defaults...)
+
+	result := pairStorageUploadFile{
+		pairs: pairs,
+	}
+
+	for i, v := range pairs {
+		switch v.Key {
+		case "checkpoint_dir":
+			if result.HasCheckpointDir {
+				continue
+			}
+			result.HasCheckpointDir = true
+			result.CheckpointDir = v.Value.(string)
+			continue
+		case "concurrency":
+			if result.HasConcurrency {
+				continue
+			}
+			result.HasConcurrency = true
+			result.Concurrency = v.Value.(int)
+			continue
+		case "content_type":
+			// The content type inferred by the file takes precedence over the default one.
+			if result.HasContentType || i >= len(opts) {
+				continue
+			}
+			result.HasContentType = true
+			result.ContentType = v.Value.(string)
+			continue
+		case "part_size":
+			if result.HasPartSize {
+				continue
+			}
+			result.HasPartSize = true
+			result.PartSize = v.Value.(int64)
+			continue
+		case "storage_class":
+			if result.HasStorageClass {
+				continue
+			}
+			result.HasStorageClass = true
+			result.StorageClass = v.Value.(string)
+			continue
+		default:
+			// The default pairs are shared with create_multipart, skip the ones not supported by upload_file.
+			if i >= len(opts) {
+				continue
+			}
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageUploadFile{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageWriteFile is the parsed struct
+type pairStorageWriteFile struct {
+	pairs                                 []Pair
+	HasContentType                        bool
+	ContentType                           string
+	HasObjectACL                          bool
+	ObjectACL                             string
+	HasServerSideDataEncryption           bool
+	ServerSideDataEncryption              string
+	HasServerSideEncryption               bool
+	ServerSideEncryption                  string
+	HasServerSideEncryptionCustomerKey    bool
+	ServerSideEncryptionCustomerKey       []byte
+	HasServerSideEncryptionCustomerKeyMd5 bool
+	ServerSideEncryptionCustomerKeyMd5    string
+	HasServerSideEncryptionKeyID          bool
+	ServerSideEncryptionKeyID             string
+	HasStorageClass                       bool
+	StorageClass                          string
+	HasUserMetadata                       bool
+	UserMetadata                          map[string]string
+}
+
+// parsePairStorageWriteFile will parse Pair slice into *pairStorageWriteFile
+//
+// defaults are the default pairs of write, which are applied after opts.
+func (s *Storage) parsePairStorageWriteFile(opts []Pair, defaults []Pair) (pairStorageWriteFile, error) {
+	pairs := make([]Pair, 0, len(opts)+len(defaults))
+	pairs = append(append(pairs, opts...), defaults...)
+
+	result := pairStorageWriteFile{
+		pairs: pairs,
+	}
+
+	for i, v := range pairs {
+		switch v.Key {
+		case "content_type":
+			// The content type inferred by the file takes precedence over the default one.
+			if result.HasContentType || i >= len(opts) {
+				continue
+			}
+			result.HasContentType = true
+			result.ContentType = v.Value.(string)
+			continue
+		case "object_acl":
+			if result.HasObjectACL {
+				continue
+			}
+			result.HasObjectACL = true
+			result.ObjectACL = v.Value.(string)
+			continue
+		case "server_side_data_encryption":
+			if result.HasServerSideDataEncryption {
+				continue
+			}
+			result.HasServerSideDataEncryption = true
+			result.ServerSideDataEncryption = v.Value.(string)
+			continue
+		case "server_side_encryption":
+			if result.HasServerSideEncryption {
+				continue
+			}
+			result.HasServerSideEncryption = true
+			result.ServerSideEncryption = v.Value.(string)
+			continue
+		case "server_side_encryption_customer_key":
+			if result.HasServerSideEncryptionCustomerKey {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKey = true
+			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
+			continue
+		case "server_side_encryption_customer_key_md5":
+			if result.HasServerSideEncryptionCustomerKeyMd5 {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKeyMd5 = true
+			result.ServerSideEncryptionCustomerKeyMd5 = v.Value.(string)
+			continue
+		case "server_side_encryption_key_id":
+			if result.HasServerSideEncryptionKeyID {
+				continue
+			}
+			result.HasServerSideEncryptionKeyID = true
+			result.ServerSideEncryptionKeyID = v.Value.(string)
+			continue
+		case "storage_class":
+			if result.HasStorageClass {
+				continue
+			}
+			result.HasStorageClass = true
+			result.StorageClass = v.Value.(string)
+			continue
+		case "user_metadata":
+			if result.HasUserMetadata {
+				continue
+			}
+			result.HasUserMetadata = true
+			result.UserMetadata = v.Value.(map[string]string)
+			continue
+		default:
+			// The default pairs are shared with write, skip the ones not supported by write_file.
+			if i >= len(opts) {
+				continue
+			}
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageWriteFile{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// pairStorageWriteMultipartCopy is the parsed struct
+type pairStorageWriteMultipartCopy struct {
+	pairs                                 []Pair
+	HasServerSideEncryptionCustomerKey    bool
+	ServerSideEncryptionCustomerKey       []byte
+	HasServerSideEncryptionCustomerKeyMd5 bool
+	ServerSideEncryptionCustomerKeyMd5    string
+}
+
+// parsePairStorageWriteMultipartCopy will parse Pair slice into *pairStorageWriteMultipartCopy
+func (s *Storage) parsePairStorageWriteMultipartCopy(opts []Pair) (pairStorageWriteMultipartCopy, error) {
+	result := pairStorageWriteMultipartCopy{
+		pairs: opts,
+	}
+
+	for _, v := range opts {
+		switch v.Key {
+		case "server_side_encryption_customer_key":
+			if result.HasServerSideEncryptionCustomerKey {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKey = true
+			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
+			continue
+		case "server_side_encryption_customer_key_md5":
+			if result.HasServerSideEncryptionCustomerKeyMd5 {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKeyMd5 = true
+			result.ServerSideEncryptionCustomerKeyMd5 = v.Value.(string)
+			continue
+		default:
+			// loose_pair feature introduced in GSP-109.
+			// If user enable this feature, service should ignore not support pair error.
+			if s.features.LoosePair {
+				continue
+			}
+			return pairStorageWriteMultipartCopy{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
+// BatchDelete will delete objects in batches of at most 1000 objects.
+//
+// This function will create a context by default.
+func (s *Storage) BatchDelete(paths []string, pairs ...Pair) (result *BatchDeleteResult, err error) {
+	ctx := context.Background()
+	return s.BatchDeleteWithContext(ctx, paths, pairs...)
+}
+
+// BatchDeleteWithContext will delete objects in batches of at most 1000 objects.
+//
+// Failing to delete some objects will not return an error, callers should
+// check BatchDeleteResult.Failed instead.
+func (s *Storage) BatchDeleteWithContext(ctx context.Context, paths []string, pairs ...Pair) (result *BatchDeleteResult, err error) {
+	defer func() {
+		err = s.formatError("batch_delete", err, paths...)
+	}()
+
+	// No pairs are supported by batch_delete yet.
+	if len(pairs) > 0 && !s.features.LoosePair {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.batchDelete(ctx, paths)
+}
+
+// DownloadFile will download an object into a local file via concurrent ranged
+// reads, the download can be resumed if checkpoint_dir is set.
+//
+// This function will create a context by default.
+func (s *Storage) DownloadFile(path string, filePath string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.DownloadFileWithContext(ctx, path, filePath, pairs...)
+}
+
+// DownloadFileWithContext will download an object into a local file via concurrent
+// ranged reads, the download can be resumed if checkpoint_dir is set.
+//
+// The content will be written into a temporary file and renamed to filePath
+// once the download is finished.
+func (s *Storage) DownloadFileWithContext(ctx context.Context, path string, filePath string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("download_file", err, path)
+	}()
+
+	opt, err := s.parsePairStorageDownloadFile(pairs, s.defaultPairs.Read)
+	if err != nil {
+		return
+	}
+
+	return s.downloadFile(ctx, path, filePath, opt)
+}
+
+// Exists will check whether the object exists with a HEAD request.
+//
+// This function will create a context by default.
+func (s *Storage) Exists(path string, pairs ...Pair) (ok bool, err error) {
+	ctx := context.Background()
+	return s.ExistsWithContext(ctx, path, pairs...)
+}
+
+// ExistsWithContext will check whether the object exists with a HEAD request.
+//
+// false will be returned without error if the object doesn't exist, errors are
+// only returned for the other failures. With version_id, only the specified
+// version will be checked, and a delete marker is treated as not exist.
+func (s *Storage) ExistsWithContext(ctx context.Context, path string, pairs ...Pair) (ok bool, err error) {
+	defer func() {
+		err = s.formatError("exists", err, path)
+	}()
+
+	opt, err := s.parsePairStorageExists(pairs, s.defaultPairs.Stat)
+	if err != nil {
+		return
+	}
+
+	return s.exists(ctx, path, opt)
+}
+
+// GetObjectACL will get the canned ACL of the object.
+//
+// This function will create a context by default.
+func (s *Storage) GetObjectACL(path string, pairs ...Pair) (acl string, err error) {
+	ctx := context.Background()
+	return s.GetObjectACLWithContext(ctx, path, pairs...)
+}
+
+// GetObjectACLWithContext will get the canned ACL of the object.
+//
+// ObjectACLDefault will be returned if the object inherits the ACL of the bucket.
+func (s *Storage) GetObjectACLWithContext(ctx context.Context, path string, pairs ...Pair) (acl string, err error) {
+	defer func() {
+		err = s.formatError("get_object_acl", err, path)
+	}()
+
+	// No pairs are supported by get_object_acl yet.
+	if len(pairs) > 0 && !s.features.LoosePair {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.getObjectACL(ctx, path)
+}
+
+// QuerySignHTTPDelete will delete an object from service by using query parameters to authenticate requests.
+//
+// This function will create a context by default.
+func (s *Storage) QuerySignHTTPDelete(path string, expire time.Duration, pairs ...Pair) (req *http.Request, err error) {
+	ctx := context.Background()
+	return s.QuerySignHTTPDeleteWithContext(ctx, path, expire, pairs...)
+}
+
+// QuerySignHTTPDeleteWithContext will delete an object from service by using query parameters to authenticate requests.
+func (s *Storage) QuerySignHTTPDeleteWithContext(ctx context.Context, path string, expire time.Duration, pairs ...Pair) (req *http.Request, err error) {
+	defer func() {
+		err = s.formatError("query_sign_http_delete", err, path)
+	}()
+
+	// No pairs are supported by query_sign_http_delete yet.
+	if len(pairs) > 0 && !s.features.LoosePair {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.querySignHTTPDelete(ctx, path, expire)
+}
+
+// ReadFile will download an object into a local file, the file will be
+// written into a temporary file first and renamed to filePath once finished.
+//
+// This function will create a context by default.
+func (s *Storage) ReadFile(path string, filePath string, pairs ...Pair) (n int64, err error) {
+	ctx := context.Background()
+	return s.ReadFileWithContext(ctx, path, filePath, pairs...)
+}
+
+// ReadFileWithContext will download an object into a local file, the file will
+// be written into a temporary file first and renamed to filePath once finished,
+// so that no partial file will be left on failure.
+//
+// The temporary file is created in filePath's dir by default. If temp_dir is on
+// a different file system, the file will be copied into filePath's dir and then
+// renamed, which needs extra space there.
+func (s *Storage) ReadFileWithContext(ctx context.Context, path string, filePath string, pairs ...Pair) (n int64, err error) {
+	defer func() {
+		err = s.formatError("read_file", err, path)
+	}()
+
+	opt, err := s.parsePairStorageReadFile(pairs, s.defaultPairs.Read)
+	if err != nil {
+		return
+	}
+
+	return s.readFile(ctx, path, filePath, opt)
+}
+
+// Restore will restore an archived object so that it can be read.
+//
+// This function will create a context by default.
+func (s *Storage) Restore(path string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.RestoreWithContext(ctx, path, pairs...)
+}
+
+// RestoreWithContext will restore an archived object so that it can be read.
+//
+// ErrRestoreAlreadyInProgress will be returned if the object is being restored.
+func (s *Storage) RestoreWithContext(ctx context.Context, path string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("restore", err, path)
+	}()
+
+	opt, err := s.parsePairStorageRestore(pairs)
+	if err != nil {
+		return
+	}
+
+	return s.restore(ctx, path, opt)
+}
+
+// SelectObject will run the SQL over a CSV or JSON object and return the result stream.
+//
+// This function will create a context by default.
+func (s *Storage) SelectObject(path string, sql string, pairs ...Pair) (rc io.ReadCloser, err error) {
+	ctx := context.Background()
+	return s.SelectObjectWithContext(ctx, path, sql, pairs...)
+}
+
+// SelectObjectWithContext will run the SQL over a CSV or JSON object and return the result stream.
+//
+// Caller should close the returned io.ReadCloser after reading.
+func (s *Storage) SelectObjectWithContext(ctx context.Context, path string, sql string, pairs ...Pair) (rc io.ReadCloser, err error) {
+	defer func() {
+		err = s.formatError("select_object", err, path)
+	}()
+
+	opt, err := s.parsePairStorageSelectObject(pairs)
+	if err != nil {
+		return
+	}
+
+	return s.selectObject(ctx, path, sql, opt)
+}
+
+// SetObjectACL will replace the canned ACL of the object without uploading it again.
+//
+// This function will create a context by default.
+func (s *Storage) SetObjectACL(path string, acl string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.SetObjectACLWithContext(ctx, path, acl, pairs...)
+}
+
+// SetObjectACLWithContext will replace the canned ACL of the object without
+// uploading it again.
+//
+// acl can be ObjectACLDefault, ObjectACLPrivate, ObjectACLPublicRead or ObjectACLPublicReadWrite.
+func (s *Storage) SetObjectACLWithContext(ctx context.Context, path string, acl string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("set_object_acl", err, path)
+	}()
+
+	// No pairs are supported by set_object_acl yet.
+	if len(pairs) > 0 && !s.features.LoosePair {
+		err = services.PairUnsupportedError{Pair: pairs[0]}
+		return
+	}
+
+	return s.setObjectACL(ctx, path, acl)
+}
+
+// UpdateMeta will update the metadata of an existing object in place, the
+// content will not be transferred again.
+//
+// This function will create a context by default.
+func (s *Storage) UpdateMeta(path string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.UpdateMetaWithContext(ctx, path, pairs...)
+}
+
+// UpdateMetaWithContext will update the metadata of an existing object in
+// place, the content will not be transferred again.
+//
+// The object will be copied onto itself with `x-oss-metadata-directive: REPLACE`,
+// metadata not specified in pairs will be kept. user_metadata replaces all
+// user metadata of the object instead of merging with them. The storage class,
+// server side encryption and ACL of the object will be kept as well, and the
+// SSE-C key must be passed for the object encrypted with SSE-C.
+//
+// Objects larger than 1GB will be copied by multipart upload, which replaces
+// the object only after all parts have been copied.
+func (s *Storage) UpdateMetaWithContext(ctx context.Context, path string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("update_meta", err, path)
+	}()
+
+	opt, err := s.parsePairStorageUpdateMeta(pairs)
+	if err != nil {
+		return
+	}
+
+	return s.updateMeta(ctx, path, opt)
+}
+
+// UploadFile will upload a local file via concurrent multipart upload, the
+// upload can be resumed from the last completed part if checkpoint_dir is set.
+//
+// This function will create a context by default.
+func (s *Storage) UploadFile(path string, filePath string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.UploadFileWithContext(ctx, path, filePath, pairs...)
+}
+
+// UploadFileWithContext will upload a local file via concurrent multipart upload,
+// the upload can be resumed from the last completed part if checkpoint_dir is set.
+//
+// The checkpoint file will be removed once the upload is finished.
+func (s *Storage) UploadFileWithContext(ctx context.Context, path string, filePath string, pairs ...Pair) (err error) {
+	defer func() {
+		err = s.formatError("upload_file", err, path)
+	}()
+
+	opt, err := s.parsePairStorageUploadFile(pairs, s.defaultPairs.CreateMultipart)
+	if err != nil {
+		return
+	}
+
+	return s.uploadFile(ctx, path, filePath, opt)
+}
+
+// UploadMultipart will upload the content of r via multipart upload, parts
+// will be uploaded concurrently.
+//
+// This function will create a context by default.
+func (s *Storage) UploadMultipart(path string, r io.Reader, partSize int64, concurrency int, pairs ...Pair) (n int64, err error) {
+	ctx := context.Background()
+	return s.UploadMultipartWithContext(ctx, path, r, partSize, concurrency, pairs...)
+}
+
+// UploadMultipartWithContext will upload the content of r via multipart upload,
+// parts will be uploaded concurrently.
+//
+// r will be split into parts of partSize, at most concurrency parts will be
+// uploaded at the same time. Parts of r implementing io.ReaderAt and io.Seeker
+// like *os.File are read from r directly, otherwise they are buffered in memory
+// and concurrency will be lowered to keep the buffered parts under 256MB.
+// Pairs supported by CreateMultipart are accepted.
+//
+// The multipart upload will be aborted once any part failed, and the first
+// error will be returned.
+func (s *Storage) UploadMultipartWithContext(ctx context.Context, path string, r io.Reader, partSize int64, concurrency int, pairs ...Pair) (n int64, err error) {
+	defer func() {
+		err = s.formatError("upload_multipart", err, path)
+	}()
+
+	pairs = append(pairs, s.defaultPairs.CreateMultipart...)
+	opt, err := s.parsePairStorageCreateMultipart(pairs)
+	if err != nil {
+		return
+	}
+
+	return s.uploadMultipart(ctx, path, r, partSize, concurrency, RetryPolicy{}, opt)
+}
+
+// WriteFile will upload a local file with a single PutObject, the size and
+// the content type will be inferred from the file.
+//
+// This function will create a context by default.
+func (s *Storage) WriteFile(path string, filePath string, pairs ...Pair) (n int64, err error) {
+	ctx := context.Background()
+	return s.WriteFileWithContext(ctx, path, filePath, pairs...)
+}
+
+// WriteFileWithContext will upload a local file with a single PutObject, the
+// size and the content type will be inferred from the file.
+//
+// Pairs for the content type, ACL, storage class, server side encryption and
+// user metadata are handled as Write, and so are the default pairs of write.
+//
+// Files larger than 5GB should be uploaded via UploadFile instead.
+func (s *Storage) WriteFileWithContext(ctx context.Context, path string, filePath string, pairs ...Pair) (n int64, err error) {
+	defer func() {
+		err = s.formatError("write_file", err, path)
+	}()
+
+	opt, err := s.parsePairStorageWriteFile(pairs, s.defaultPairs.Write)
+	if err != nil {
+		return
+	}
+
+	return s.writeFile(ctx, path, filePath, opt)
+}
+
+// WriteMultipartCopy will copy a range of the src object into a multipart object's part.
+//
+// This function will create a context by default.
+func (s *Storage) WriteMultipartCopy(o *Object, src string, offset, size int64, index int, pairs ...Pair) (part *Part, err error) {
+	ctx := context.Background()
+	return s.WriteMultipartCopyWithContext(ctx, o, src, offset, size, index, pairs...)
+}
+
+// WriteMultipartCopyWithContext will copy a range of the src object into a multipart object's part.
+//
+// The same customer-provided key is used to decrypt the src object and encrypt
+// the part, which must be the key of the multipart upload.
+func (s *Storage) WriteMultipartCopyWithContext(ctx context.Context, o *Object, src string, offset, size int64, index int, pairs ...Pair) (part *Part, err error) {
+	defer func() {
+		err = s.formatError("write_multipart_copy", err, src)
+	}()
+	if !o.Mode.IsPart() {
+		err = services.ObjectModeInvalidError{Expected: ModePart, Actual: o.Mode}
+		return
+	}
+
+	opt, err := s.parsePairStorageWriteMultipartCopy(pairs)
+	if err != nil {
+		return
+	}
+
+	return s.writeMultipartCopy(ctx, o, src, offset, size, index, opt)
+}
@@ -2,18 +2,174 @@ package oss
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 
 	ps "github.com/beyondstorage/go-storage/v4/pairs"
+	"github.com/beyondstorage/go-storage/v4/services"
 	typ "github.com/beyondstorage/go-storage/v4/types"
 )
 
+// BucketInfo is the information of the bucket.
+//
+// ref: https://help.aliyun.com/document_detail/31968.html
+type BucketInfo struct {
+	// Name is the name of the bucket.
+	Name string
+	// Location is the region of the bucket, like oss-cn-hangzhou.
+	Location string
+	// CreationDate is the time that the bucket was created.
+	CreationDate time.Time
+	// StorageClass is the default storage class of objects in the bucket.
+	StorageClass string
+	// ACL is the canned ACL of the bucket, can be private, public-read or public-read-write.
+	ACL string
+	// Versioning is the versioning status of the bucket, can be Enabled or
+	// Suspended, empty means versioning has never been enabled.
+	Versioning string
+	// RedundancyType is the data redundancy type of the bucket, can be LRS or ZRS.
+	RedundancyType string
+	// ExtranetEndpoint is the public endpoint of the bucket.
+	ExtranetEndpoint string
+	// IntranetEndpoint is the internal endpoint of the bucket.
+	IntranetEndpoint string
+}
+
+// LifecycleRule is a lifecycle rule of the bucket.
+//
+// ref: https://help.aliyun.com/document_detail/31964.html
+type LifecycleRule struct {
+	// ID is the unique ID of the rule, OSS will generate one if it's empty.
+	ID string
+	// Prefix is the prefix of object keys the rule applies to, empty means the whole bucket.
+	Prefix string
+	// Enabled is whether the rule is enabled.
+	Enabled bool
+
+	// ExpirationDays is the days after the last modified time that objects
+	// will be deleted, 0 means objects will not expire.
+	ExpirationDays int
+	// AbortMultipartUploadDays is the days after the initiated time that
+	// multipart uploads will be aborted, 0 means they will not be aborted.
+	AbortMultipartUploadDays int
+	// Transitions are the storage class transitions of objects.
+	Transitions []LifecycleTransition
+}
+
+// LifecycleTransition is a storage class transition of LifecycleRule.
+type LifecycleTransition struct {
+	// Days is the days after the last modified time that objects will be transitioned.
+	Days int
+	// StorageClass is the target storage class, can be IA, Archive or ColdArchive.
+	StorageClass string
+}
+
+// LoggingConfig is the access logging configuration of the bucket.
+//
+// ref: https://help.aliyun.com/document_detail/31961.html
+type LoggingConfig struct {
+	// TargetBucket is the bucket to store the log files, must be in the same region.
+	TargetBucket string
+	// TargetPrefix is the prefix of the log files.
+	TargetPrefix string
+}
+
+// RefererConfig is the referer whitelist configuration of the bucket, which is
+// used to prevent hotlinking.
+//
+// ref: https://help.aliyun.com/document_detail/31869.html
+type RefererConfig struct {
+	// AllowEmptyReferer is whether requests without Referer are allowed.
+	AllowEmptyReferer bool
+	// Referers is the whitelist, `*` and `?` are supported as wildcards, like
+	// `https://*.example.com`. Empty means all referers are allowed.
+	Referers []string
+}
+
+// WebsiteConfig is the static website hosting configuration of the bucket.
+//
+// ref: https://help.aliyun.com/document_detail/31962.html
+type WebsiteConfig struct {
+	// IndexDocument is the default page while accessing a directory, like `index.html`.
+	IndexDocument string
+	// ErrorDocument is the page returned while the object does not exist, like `error.html`.
+	ErrorDocument string
+	// RoutingRules are the redirect rules, which will be matched in the order of RuleNumber.
+	RoutingRules []WebsiteRoutingRule
+}
+
+// WebsiteRoutingRule is a routing rule of WebsiteConfig.
+type WebsiteRoutingRule struct {
+	// RuleNumber is the priority of the rule, must be positive and unique.
+	RuleNumber int
+	// Condition is the condition that requests must match.
+	Condition WebsiteCondition
+	// Redirect is how to redirect the matched requests.
+	Redirect WebsiteRedirect
+}
+
+// WebsiteCondition is the condition of WebsiteRoutingRule.
+type WebsiteCondition struct {
+	// KeyPrefixEquals matches the requests whose object key has this prefix.
+	KeyPrefixEquals string
+	// HTTPErrorCodeReturnedEquals matches the requests which return this status code, like 404.
+	HTTPErrorCodeReturnedEquals int
+	// IncludeHeaders matches the requests which have all of these headers.
+	IncludeHeaders map[string]string
+}
+
+// WebsiteRedirect is the redirect of WebsiteRoutingRule.
+type WebsiteRedirect struct {
+	// Type is the redirect type, can be Mirror, External, Internal or AliCDN.
+	Type string
+	// PassQueryString is whether to pass the query string of the request.
+	PassQueryString bool
+	// MirrorURL is the origin to fetch from, only for Mirror.
+	MirrorURL string
+	// Protocol is the protocol of the redirect location, can be http or https.
+	Protocol string
+	// HostName is the host of the redirect location.
+	HostName string
+	// ReplaceKeyPrefixWith replaces the matched KeyPrefixEquals.
+	ReplaceKeyPrefixWith string
+	// ReplaceKeyWith replaces the whole object key, conflicts with ReplaceKeyPrefixWith.
+	ReplaceKeyWith string
+	// HTTPRedirectCode is the status code returned, can be 301, 302 or 307.
+	HTTPRedirectCode int
+}
+
+// Available website redirect types.
+const (
+	WebsiteRedirectMirror   = "Mirror"
+	WebsiteRedirectExternal = "External"
+	WebsiteRedirectInternal = "Internal"
+	WebsiteRedirectAliCDN   = "AliCDN"
+)
+
 func (s *Service) create(ctx context.Context, name string, opt pairServiceCreate) (store typ.Storager, err error) {
+	// OSS will create the bucket in the region of the endpoint, so location must match the endpoint.
+	// ref: https://help.aliyun.com/document_detail/31959.html
+	if opt.HasLocation && !checkEndpointLocation(s.service.Config.Endpoint, opt.Location) {
+		return nil, services.PairUnsupportedError{Pair: ps.WithLocation(opt.Location)}
+	}
+
 	st, err := s.newStorage(ps.WithName(name))
 	if err != nil {
 		return nil, err
 	}
-	err = s.service.CreateBucket(name)
+
+	options := make([]oss.Option, 0, 2)
+	if opt.HasStorageClass {
+		options = append(options, oss.StorageClass(oss.StorageClassType(opt.StorageClass)))
+	}
+	if opt.HasBucketACL {
+		options = append(options, oss.ACL(oss.ACLType(opt.BucketACL)))
+	}
+
+	err = s.service.CreateBucket(name, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +184,42 @@ func (s *Service) delete(ctx context.Context, name string, opt pairServiceDelete
 	return nil
 }
 
+func (s *Service) deleteLifecycle(ctx context.Context, name string) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	return s.service.DeleteBucketLifecycle(name)
+}
+
+func (s *Service) deleteLogging(ctx context.Context, name string) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	return s.service.DeleteBucketLogging(name)
+}
+
+func (s *Service) deletePolicy(ctx context.Context, name string) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	return s.service.DeleteBucketPolicy(name)
+}
+
+func (s *Service) deleteWebsite(ctx context.Context, name string) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	return s.service.DeleteBucketWebsite(name)
+}
+
 func (s *Service) get(ctx context.Context, name string, opt pairServiceGet) (store typ.Storager, err error) {
 	st, err := s.newStorage(ps.WithName(name))
 	if err != nil {
@@ -36,6 +228,119 @@ func (s *Service) get(ctx context.Context, name string, opt pairServiceGet) (sto
 	return st, nil
 }
 
+func (s *Service) getBucketInfo(ctx context.Context, name string) (info *BucketInfo, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	output, err := s.service.GetBucketInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	return &BucketInfo{
+		Name:             output.BucketInfo.Name,
+		Location:         output.BucketInfo.Location,
+		CreationDate:     output.BucketInfo.CreationDate.UTC(),
+		StorageClass:     output.BucketInfo.StorageClass,
+		ACL:              output.BucketInfo.ACL,
+		Versioning:       output.BucketInfo.Versioning,
+		RedundancyType:   output.BucketInfo.RedundancyType,
+		ExtranetEndpoint: output.BucketInfo.ExtranetEndpoint,
+		IntranetEndpoint: output.BucketInfo.IntranetEndpoint,
+	}, nil
+}
+
+func (s *Service) getLifecycle(ctx context.Context, name string) (rules []LifecycleRule, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	output, err := s.service.GetBucketLifecycle(name)
+	if err != nil {
+		// OSS will return NoSuchLifecycle if no rules have been set.
+		if checkError(err, responseCodeNoSuchLifecycle) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return parseLifecycleRules(output.Rules), nil
+}
+
+func (s *Service) getLogging(ctx context.Context, name string) (cfg *LoggingConfig, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	output, err := s.service.GetBucketLogging(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// OSS will return an empty LoggingEnabled if the access logging is disabled.
+	if output.LoggingEnabled.TargetBucket == "" {
+		return nil, nil
+	}
+	return &LoggingConfig{
+		TargetBucket: output.LoggingEnabled.TargetBucket,
+		TargetPrefix: output.LoggingEnabled.TargetPrefix,
+	}, nil
+}
+
+func (s *Service) getPolicy(ctx context.Context, name string) (policy string, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	policy, err = s.service.GetBucketPolicy(name)
+	if err != nil {
+		// OSS will return NoSuchBucketPolicy if no policy has been set.
+		if checkError(err, responseCodeNoSuchBucketPolicy) {
+			return "", nil
+		}
+		return "", err
+	}
+	return policy, nil
+}
+
+func (s *Service) getReferer(ctx context.Context, name string) (cfg *RefererConfig, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	output, err := s.service.GetBucketReferer(name)
+	if err != nil {
+		return nil, err
+	}
+	return &RefererConfig{
+		AllowEmptyReferer: output.AllowEmptyReferer,
+		Referers:          output.RefererList,
+	}, nil
+}
+
+func (s *Service) getWebsite(ctx context.Context, name string) (cfg *WebsiteConfig, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	output, err := s.service.GetBucketWebsite(name)
+	if err != nil {
+		// OSS will return NoSuchWebsiteConfiguration if static website hosting is not enabled.
+		if checkError(err, responseCodeNoSuchWebsiteConfiguration) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return parseWebsiteConfig(oss.WebsiteXML(output)), nil
+}
+
 func (s *Service) list(ctx context.Context, opt pairServiceList) (it *typ.StoragerIterator, err error) {
 	input := &storagePageStatus{
 		maxKeys: 200,
@@ -60,6 +365,8 @@ func (s *Service) nextStoragePage(ctx context.Context, page *typ.StoragerPage) e
 		if err != nil {
 			return err
 		}
+		properties := v
+		store.properties = &properties
 
 		page.Data = append(page.Data, store)
 	}
@@ -71,3 +378,122 @@ func (s *Service) nextStoragePage(ctx context.Context, page *typ.StoragerPage) e
 	input.marker = output.NextMarker
 	return nil
 }
+
+func (s *Service) setLifecycle(ctx context.Context, name string, rules []LifecycleRule) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if len(rules) == 0 {
+		return fmt.Errorf("lifecycle rules are empty: %w", services.ErrRestrictionDissatisfied)
+	}
+	for _, rule := range rules {
+		if rule.ExpirationDays < 0 || rule.AbortMultipartUploadDays < 0 {
+			return fmt.Errorf("lifecycle rule %s has negative days: %w", rule.ID, services.ErrRestrictionDissatisfied)
+		}
+		for _, v := range rule.Transitions {
+			if v.Days <= 0 {
+				return fmt.Errorf("lifecycle rule %s has invalid transition days %d: %w",
+					rule.ID, v.Days, services.ErrRestrictionDissatisfied)
+			}
+		}
+	}
+
+	return s.service.SetBucketLifecycle(name, formatLifecycleRules(rules))
+}
+
+func (s *Service) setLogging(ctx context.Context, name string, cfg LoggingConfig) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	// The target bucket must exist and be in the same region, check it here so
+	// that we can return a clear error.
+	//
+	// ref: https://help.aliyun.com/document_detail/31900.html
+	if cfg.TargetBucket != name {
+		target, err := s.service.GetBucketInfo(cfg.TargetBucket)
+		if err != nil {
+			return err
+		}
+		source, err := s.service.GetBucketInfo(name)
+		if err != nil {
+			return err
+		}
+		if target.BucketInfo.Location != source.BucketInfo.Location {
+			return fmt.Errorf("target bucket %s is in %s but bucket is in %s: %w",
+				cfg.TargetBucket, target.BucketInfo.Location, source.BucketInfo.Location, services.ErrRestrictionDissatisfied)
+		}
+	}
+
+	return s.service.SetBucketLogging(name, cfg.TargetBucket, cfg.TargetPrefix, true)
+}
+
+func (s *Service) setPolicy(ctx context.Context, name string, policy string) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if !json.Valid([]byte(policy)) {
+		return fmt.Errorf("policy is not valid JSON: %w", services.ErrRestrictionDissatisfied)
+	}
+
+	return s.service.SetBucketPolicy(name, policy)
+}
+
+func (s *Service) setReferer(ctx context.Context, name string, cfg RefererConfig) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	for _, v := range cfg.Referers {
+		err = checkReferer(v)
+		if err != nil {
+			return
+		}
+	}
+
+	return s.service.SetBucketReferer(name, cfg.Referers, cfg.AllowEmptyReferer)
+}
+
+func (s *Service) setWebsite(ctx context.Context, name string, cfg WebsiteConfig) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if cfg.IndexDocument == "" {
+		return fmt.Errorf("website index document is empty: %w", services.ErrRestrictionDissatisfied)
+	}
+	numbers := make(map[int]struct{}, len(cfg.RoutingRules))
+	for _, rule := range cfg.RoutingRules {
+		if rule.RuleNumber <= 0 {
+			return fmt.Errorf("website routing rule has invalid number %d: %w", rule.RuleNumber, services.ErrRestrictionDissatisfied)
+		}
+		if _, ok := numbers[rule.RuleNumber]; ok {
+			return fmt.Errorf("website routing rule number %d is duplicated: %w", rule.RuleNumber, services.ErrRestrictionDissatisfied)
+		}
+		numbers[rule.RuleNumber] = struct{}{}
+
+		switch rule.Redirect.Type {
+		case WebsiteRedirectMirror:
+			if rule.Redirect.MirrorURL == "" {
+				return fmt.Errorf("website routing rule %d has empty mirror url: %w", rule.RuleNumber, services.ErrRestrictionDissatisfied)
+			}
+		case WebsiteRedirectExternal, WebsiteRedirectInternal, WebsiteRedirectAliCDN:
+		default:
+			return fmt.Errorf("website routing rule %d has invalid redirect type %q: %w",
+				rule.RuleNumber, rule.Redirect.Type, services.ErrRestrictionDissatisfied)
+		}
+		if rule.Redirect.ReplaceKeyWith != "" && rule.Redirect.ReplaceKeyPrefixWith != "" {
+			return fmt.Errorf("website routing rule %d has both replace key and replace key prefix: %w",
+				rule.RuleNumber, services.ErrRestrictionDissatisfied)
+		}
+	}
+
+	return s.service.SetBucketWebsiteDetail(name, formatWebsiteConfig(cfg))
+}
@@ -1,10 +1,18 @@
 package oss
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
@@ -16,11 +24,85 @@ import (
 	. "github.com/beyondstorage/go-storage/v4/types"
 )
 
+// BatchDeleteResult is the result of BatchDelete.
+type BatchDeleteResult struct {
+	// Deleted contains the paths which have been deleted.
+	Deleted []string
+	// Failed contains the paths which failed to be deleted and their errors.
+	Failed map[string]error
+}
+
+func (s *Storage) batchDelete(ctx context.Context, paths []string) (result *BatchDeleteResult, err error) {
+	result = &BatchDeleteResult{
+		Failed: make(map[string]error),
+	}
+
+	for start := 0; start < len(paths); start += deleteObjectsMaximum {
+		end := start + deleteObjectsMaximum
+		if end > len(paths) {
+			end = len(paths)
+		}
+
+		// keys maps the abs path back to the path passed by user.
+		keys := make(map[string]string, end-start)
+		rps := make([]string, 0, end-start)
+		for _, path := range paths[start:end] {
+			rp, err := s.getAbsPath(path)
+			if err != nil {
+				result.Failed[path] = err
+				continue
+			}
+			keys[rp] = path
+			rps = append(rps, rp)
+		}
+		if len(rps) == 0 {
+			continue
+		}
+
+		output, err := s.bucket.DeleteObjects(rps)
+		if err != nil {
+			// The whole batch failed, mark all paths in it as failed.
+			for _, path := range paths[start:end] {
+				result.Failed[path] = formatError(err)
+			}
+			continue
+		}
+
+		for _, rp := range output.DeletedObjects {
+			path, ok := keys[rp]
+			if !ok {
+				continue
+			}
+			result.Deleted = append(result.Deleted, path)
+			delete(keys, rp)
+		}
+		// OSS only returns the deleted objects, the remaining ones are failed.
+		for _, path := range keys {
+			result.Failed[path] = fmt.Errorf("%w: object not deleted", services.ErrUnexpected)
+		}
+	}
+	return result, nil
+}
+
 func (s *Storage) commitAppend(ctx context.Context, o *Object, opt pairStorageCommitAppend) (err error) {
+	// OSS append object is readable after every append, there is nothing to commit.
 	return
 }
 
 func (s *Storage) completeMultipart(ctx context.Context, o *Object, parts []*Part, opt pairStorageCompleteMultipart) (err error) {
+	if len(parts) > multipartNumberMaximum {
+		err = fmt.Errorf("multipart number limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
+	}
+	// All parts except the last one must not be smaller than multipartSizeMinimum.
+	for i, v := range parts {
+		if i != len(parts)-1 && v.Size < multipartSizeMinimum {
+			err = fmt.Errorf("part %d size %d is smaller than minimum %d: %w",
+				v.Index, v.Size, multipartSizeMinimum, services.ErrRestrictionDissatisfied)
+			return
+		}
+	}
+
 	imur := oss.InitiateMultipartUploadResult{
 		Bucket:   s.bucket.BucketName,
 		Key:      o.ID,
@@ -37,7 +119,12 @@ func (s *Storage) completeMultipart(ctx context.Context, o *Object, parts []*Par
 		})
 	}
 
-	_, err = s.bucket.CompleteMultipartUpload(imur, uploadParts)
+	options := make([]oss.Option, 0, 1)
+	if opt.HasForbidOverwrite {
+		options = append(options, oss.ForbidOverWrite(opt.ForbidOverwrite))
+	}
+
+	_, err = s.bucket.CompleteMultipartUpload(imur, uploadParts, options...)
 	if err != nil {
 		return
 	}
@@ -47,8 +134,187 @@ func (s *Storage) completeMultipart(ctx context.Context, o *Object, parts []*Par
 	return
 }
 
+func (s *Storage) copy(ctx context.Context, src string, dst string, opt pairStorageCopy) (err error) {
+	replace := false
+	if opt.HasMetadataDirective {
+		switch opt.MetadataDirective {
+		case MetadataDirectiveCopy:
+		case MetadataDirectiveReplace:
+			replace = true
+		default:
+			err = services.PairUnsupportedError{Pair: WithMetadataDirective(opt.MetadataDirective)}
+			return
+		}
+	}
+	// OSS ignores the metadata headers with COPY, reject them instead of ignoring silently.
+	if !replace {
+		var p Pair
+		switch {
+		case opt.HasContentType:
+			p = ps.WithContentType(opt.ContentType)
+		case opt.HasCacheControl:
+			p = WithCacheControl(opt.CacheControl)
+		case opt.HasContentDisposition:
+			p = WithContentDisposition(opt.ContentDisposition)
+		case opt.HasContentEncoding:
+			p = WithContentEncoding(opt.ContentEncoding)
+		case opt.HasExpires:
+			p = WithExpires(opt.Expires)
+		case opt.HasUserMetadata:
+			p = WithUserMetadata(opt.UserMetadata)
+		}
+		if p.Key != "" {
+			err = services.PairUnsupportedError{Pair: p}
+			return
+		}
+	}
+
+	rs, err := s.getAbsPath(src)
+	if err != nil {
+		return
+	}
+	rd, err := s.getAbsPath(dst)
+	if err != nil {
+		return
+	}
+
+	// The same customer-provided key is used for the src and the dst object.
+	var sseOptions, srcSSEOptions []oss.Option
+	if opt.HasServerSideEncryptionCustomerKey || opt.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err = formatServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return
+		}
+		srcSSEOptions, err = formatCopySourceServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return
+		}
+	}
+
+	// Stat the src object first so that we can preserve its storage class and
+	// choose the copy method by its size.
+	meta, err := s.bucket.GetObjectDetailedMeta(rs, sseOptions...)
+	if err != nil {
+		return
+	}
+
+	size, err := strconv.ParseInt(meta.Get(headers.ContentLength), 10, 64)
+	if err != nil {
+		return
+	}
+
+	// Copying an object onto itself with another storage class is the way OSS
+	// changes the storage class in place, so storage_class takes precedence.
+	options := make([]oss.Option, 0, 2)
+	if opt.HasStorageClass {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opt.StorageClass)))
+	} else if v := meta.Get(storageClassHeader); v != "" {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(v)))
+	}
+	if opt.HasForbidOverwrite {
+		options = append(options, oss.ForbidOverWrite(opt.ForbidOverwrite))
+	}
+	options = append(options, sseOptions...)
+
+	var metaOptions []oss.Option
+	if replace {
+		metaOptions = formatCopyMetadataOptions(opt)
+	}
+
+	// CopyObject can only copy objects smaller than 1GB, larger objects need to be copied by UploadPartCopy.
+	if size > copySizeMaximum {
+		// The metadata will not be copied by multipart upload, carry it over from the src object.
+		if !replace {
+			metaOptions = formatSourceMetadataOptions(meta)
+		}
+		return s.copyMultipart(rs, rd, size, meta.Get(oss.HTTPHeaderEtag), append(options, metaOptions...), opt)
+	}
+
+	if replace {
+		options = append(options, oss.MetadataDirective(oss.MetaReplace))
+		options = append(options, metaOptions...)
+	}
+	options = append(options, srcSSEOptions...)
+
+	_, err = s.bucket.CopyObject(rs, rd, options...)
+	if err != nil {
+		return
+	}
+	return nil
+}
+
+// copyMultipart will copy rs to rd via UploadPartCopy, rd will only be replaced
+// once all parts have been copied. Parts are copied only if rs still matches
+// srcETag, so that the parts will not come from different versions of rs.
+func (s *Storage) copyMultipart(rs, rd string, size int64, srcETag string, options []oss.Option, opt pairStorageCopy) (err error) {
+	imur, err := s.bucket.InitiateMultipartUpload(rd, options...)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			// Abort the multipart upload to avoid leaving parts behind, the
+			// copy error is more meaningful than the abort one.
+			_ = s.bucket.AbortMultipartUpload(imur)
+		}
+	}()
+
+	// Parts must be encrypted with the same customer-provided key as the multipart upload,
+	// and the src object is decrypted with the same key.
+	var partOptions []oss.Option
+	if srcETag != "" {
+		partOptions = append(partOptions, oss.CopySourceIfMatch(srcETag))
+	}
+	if opt.HasServerSideEncryptionCustomerKey || opt.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err := formatServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return err
+		}
+		srcSSEOptions, err := formatCopySourceServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return err
+		}
+		partOptions = append(append(partOptions, sseOptions...), srcSSEOptions...)
+	}
+
+	var parts []oss.UploadPart
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+multipartSizeMaximum, number+1 {
+		partSize := int64(multipartSizeMaximum)
+		if size-offset < partSize {
+			partSize = size - offset
+		}
+
+		var part oss.UploadPart
+		part, err = s.bucket.UploadPartCopy(imur, s.bucket.BucketName, rs, offset, partSize, number, partOptions...)
+		if err != nil {
+			return
+		}
+		parts = append(parts, part)
+	}
+
+	// The target may be created while copying parts, check it again while completing.
+	var completeOptions []oss.Option
+	if opt.HasForbidOverwrite {
+		completeOptions = append(completeOptions, oss.ForbidOverWrite(opt.ForbidOverwrite))
+	}
+	_, err = s.bucket.CompleteMultipartUpload(imur, parts, completeOptions...)
+	if err != nil {
+		return
+	}
+	return nil
+}
+
 func (s *Storage) create(path string, opt pairStorageCreate) (o *Object) {
-	rp := s.getAbsPath(path)
+	// Create can't return error, panic so that the invalid path will not be used silently.
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		panic(services.StorageError{
+			Op:       "create",
+			Err:      err,
+			Storager: s,
+			Path:     []string{path},
+		})
+	}
 
 	// Handle create multipart object separately.
 	if opt.HasMultipartID {
@@ -74,7 +340,22 @@ func (s *Storage) create(path string, opt pairStorageCreate) (o *Object) {
 }
 
 func (s *Storage) createAppend(ctx context.Context, path string, opt pairStorageCreateAppend) (o *Object, err error) {
-	rp := s.getAbsPath(path)
+	sseOptions, err := formatServerSideEncryptionOptions(serverSideEncryptionPairs{
+		HasServerSideEncryption:      opt.HasServerSideEncryption,
+		ServerSideEncryption:         opt.ServerSideEncryption,
+		HasServerSideDataEncryption:  opt.HasServerSideDataEncryption,
+		ServerSideDataEncryption:     opt.ServerSideDataEncryption,
+		HasServerSideEncryptionKeyID: opt.HasServerSideEncryptionKeyID,
+		ServerSideEncryptionKeyID:    opt.ServerSideEncryptionKeyID,
+	})
+	if err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
 	// oss `append` doesn't support `overwrite`, so we need to check and delete the object if exists.
 	// ref: [GSP-134](https://github.com/beyondstorage/go-storage/blob/master/docs/rfcs/134-write-behavior-consistency.md)
@@ -96,11 +377,9 @@ func (s *Storage) createAppend(ctx context.Context, path string, opt pairStorage
 		options = append(options, oss.ContentType(opt.ContentType))
 	}
 	if opt.HasStorageClass {
-		options = append(options, oss.StorageClass(oss.StorageClassType(opt.StorageClass)))
-	}
-	if opt.HasServerSideEncryption {
-		options = append(options, oss.ServerSideEncryption(opt.ServerSideEncryption))
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opt.StorageClass)))
 	}
+	options = append(options, sseOptions...)
 
 	offset, err := s.bucket.AppendObject(rp, nil, 0, options...)
 	if err != nil {
@@ -123,6 +402,9 @@ func (s *Storage) createAppend(ctx context.Context, path string, opt pairStorage
 	if opt.HasServerSideEncryption {
 		sm.ServerSideEncryption = opt.ServerSideEncryption
 	}
+	if opt.HasServerSideEncryptionKeyID {
+		sm.ServerSideEncryptionKeyID = opt.ServerSideEncryptionKeyID
+	}
 	o.SetSystemMetadata(sm)
 
 	return o, nil
@@ -133,7 +415,10 @@ func (s *Storage) createDir(ctx context.Context, path string, opt pairStorageCre
 		err = NewOperationNotImplementedError("create_dir")
 		return
 	}
-	rp := s.getAbsPath(path)
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
 	// Add `/` at the end of path to simulate directory.
 	// ref: https://help.aliyun.com/document_detail/31978.html#title-gkg-amg-aes
@@ -142,7 +427,7 @@ func (s *Storage) createDir(ctx context.Context, path string, opt pairStorageCre
 	options := make([]oss.Option, 0)
 	options = append(options, oss.ContentLength(0))
 	if opt.HasStorageClass {
-		options = append(options, oss.StorageClass(oss.StorageClassType(opt.StorageClass)))
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opt.StorageClass)))
 	}
 
 	err = s.bucket.PutObject(rp, nil, options...)
@@ -158,8 +443,14 @@ func (s *Storage) createDir(ctx context.Context, path string, opt pairStorageCre
 }
 
 func (s *Storage) createLink(ctx context.Context, path string, target string, opt pairStorageCreateLink) (o *Object, err error) {
-	rt := s.getAbsPath(target)
-	rp := s.getAbsPath(path)
+	rt, err := s.getAbsPath(target)
+	if err != nil {
+		return
+	}
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
 	// oss `symlink` supports `overwrite`, so we don't need to check if path exists.
 	err = s.bucket.PutSymlink(rp, rt)
@@ -179,23 +470,40 @@ func (s *Storage) createLink(ctx context.Context, path string, target string, op
 }
 
 func (s *Storage) createMultipart(ctx context.Context, path string, opt pairStorageCreateMultipart) (o *Object, err error) {
-	rp := s.getAbsPath(path)
+	sseOptions, err := formatServerSideEncryptionOptions(serverSideEncryptionPairs{
+		HasServerSideEncryption:               opt.HasServerSideEncryption,
+		ServerSideEncryption:                  opt.ServerSideEncryption,
+		HasServerSideDataEncryption:           opt.HasServerSideDataEncryption,
+		ServerSideDataEncryption:              opt.ServerSideDataEncryption,
+		HasServerSideEncryptionKeyID:          opt.HasServerSideEncryptionKeyID,
+		ServerSideEncryptionKeyID:             opt.ServerSideEncryptionKeyID,
+		HasServerSideEncryptionCustomerKey:    opt.HasServerSideEncryptionCustomerKey,
+		ServerSideEncryptionCustomerKey:       opt.ServerSideEncryptionCustomerKey,
+		HasServerSideEncryptionCustomerKeyMd5: opt.HasServerSideEncryptionCustomerKeyMd5,
+		ServerSideEncryptionCustomerKeyMd5:    opt.ServerSideEncryptionCustomerKeyMd5,
+	})
+	if err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
 	options := make([]oss.Option, 0, 3)
-	if opt.HasContentType {
+	if opt.HasContentType && opt.ContentType != "" {
 		options = append(options, oss.ContentType(opt.ContentType))
 	}
 	if opt.HasStorageClass {
-		options = append(options, oss.StorageClass(oss.StorageClassType(opt.StorageClass)))
-	}
-	if opt.HasServerSideEncryption {
-		options = append(options, oss.ServerSideEncryption(opt.ServerSideEncryption))
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opt.StorageClass)))
 	}
-	if opt.HasServerSideDataEncryption {
-		options = append(options, oss.ServerSideDataEncryption(opt.ServerSideDataEncryption))
+	options = append(options, sseOptions...)
+	if opt.HasObjectACL {
+		options = append(options, oss.ObjectACL(oss.ACLType(opt.ObjectACL)))
 	}
-	if opt.HasServerSideEncryptionKeyID {
-		options = append(options, oss.ServerSideEncryptionKeyID(opt.ServerSideEncryptionKeyID))
+	if opt.HasForbidOverwrite {
+		options = append(options, oss.ForbidOverWrite(opt.ForbidOverwrite))
 	}
 
 	output, err := s.bucket.InitiateMultipartUpload(rp, options...)
@@ -213,7 +521,15 @@ func (s *Storage) createMultipart(ctx context.Context, path string, opt pairStor
 }
 
 func (s *Storage) delete(ctx context.Context, path string, opt pairStorageDelete) (err error) {
-	rp := s.getAbsPath(path)
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
 	if opt.HasMultipartID {
 		err = s.bucket.AbortMultipartUpload(oss.InitiateMultipartUploadResult{
@@ -241,22 +557,137 @@ func (s *Storage) delete(ctx context.Context, path string, opt pairStorageDelete
 		rp += "/"
 	}
 
+	// Delete without version_id will create a delete marker if versioning is enabled.
+	options := make([]oss.Option, 0, 1)
+	if opt.HasVersionID {
+		options = append(options, oss.VersionId(opt.VersionID))
+	}
+
 	// OSS DeleteObject is idempotent, so we don't need to check NoSuchKey error.
 	//
 	// References
 	// - [GSP-46](https://github.com/beyondstorage/specs/blob/master/rfcs/46-idempotent-delete.md)
 	// - https://help.aliyun.com/document_detail/31982.html
-	err = s.bucket.DeleteObject(rp)
+	err = s.retry(ctx, opt.RetryPolicy, func() error {
+		return s.bucket.DeleteObject(rp, options...)
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (s *Storage) downloadFile(ctx context.Context, path string, filePath string, opt pairStorageDownloadFile) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	partSize := int64(resumablePartSizeDefault)
+	if opt.HasPartSize {
+		partSize = opt.PartSize
+	}
+	if partSize <= 0 {
+		err = services.PairUnsupportedError{Pair: WithPartSize(opt.PartSize)}
+		return
+	}
+
+	options := make([]oss.Option, 0, 3)
+	if opt.HasConcurrency && opt.Concurrency > 0 {
+		options = append(options, oss.Routines(opt.Concurrency))
+	}
+	if opt.HasCheckpointDir {
+		// OSS SDK will not create the checkpoint dir.
+		err = os.MkdirAll(opt.CheckpointDir, 0755)
+		if err != nil {
+			return
+		}
+		options = append(options, oss.CheckpointDir(true, opt.CheckpointDir))
+	}
+	if opt.HasVersionID {
+		options = append(options, oss.VersionId(opt.VersionID))
+	}
+
+	return s.bucket.DownloadFile(rp, filePath, partSize, options...)
+}
+
+func (s *Storage) exists(ctx context.Context, path string, opt pairStorageExists) (ok bool, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	options := make([]oss.Option, 0, 1)
+	if opt.HasVersionID {
+		options = append(options, oss.VersionId(opt.VersionID))
+	}
+
+	// IsObjectExist will send GetObjectMeta, which returns fewer headers than
+	// HeadObject, and return false for 404.
+	ok, err = s.bucket.IsObjectExist(rp, options...)
+	// OSS will return 405 if the specified version is a delete marker.
+	if err != nil && opt.HasVersionID {
+		if e, isServiceError := err.(oss.ServiceError); isServiceError && e.StatusCode == http.StatusMethodNotAllowed {
+			ok, err = false, nil
+		}
+	}
+	if err != nil || ok {
+		return
+	}
+	// The object may be reported as not exist because of a wrong bucket name.
+	return false, s.checkBucketExist()
+}
+
+func (s *Storage) getObjectACL(ctx context.Context, path string) (acl string, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	output, err := s.bucket.GetObjectACL(rp)
+	if err != nil {
+		return
+	}
+	return output.ACL, nil
+}
+
 func (s *Storage) list(ctx context.Context, path string, opt pairStorageList) (oi *ObjectIterator, err error) {
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
 	input := &objectPageStatus{
 		maxKeys: 200,
-		prefix:  s.getAbsPath(path),
+		prefix:  rp,
+		workDir: strings.TrimPrefix(s.workDir, "/"),
+	}
+	if opt.HasListPageSize {
+		if opt.ListPageSize <= 0 || opt.ListPageSize > listPageSizeMaximum {
+			return nil, services.PairUnsupportedError{Pair: WithListPageSize(opt.ListPageSize)}
+		}
+		input.maxKeys = opt.ListPageSize
+	}
+	if opt.HasListStartAfter && opt.ListStartAfter != "" {
+		input.marker, err = s.getAbsPath(opt.ListStartAfter)
+		if err != nil {
+			return
+		}
 	}
 
 	if !opt.HasListMode {
@@ -265,6 +696,29 @@ func (s *Storage) list(ctx context.Context, path string, opt pairStorageList) (o
 		opt.ListMode = ListModePrefix
 	}
 
+	listVersions := opt.HasListVersions && opt.ListVersions
+	if listVersions && !opt.ListMode.IsPrefix() {
+		return nil, services.PairUnsupportedError{Pair: WithListVersions()}
+	}
+
+	// Prefixes can only be listed concurrently in prefix mode, and the listing
+	// can't be resumed via list_start_after.
+	if opt.HasConcurrency {
+		if opt.Concurrency <= 0 || !opt.ListMode.IsPrefix() || listVersions {
+			return nil, services.PairUnsupportedError{Pair: WithConcurrency(opt.Concurrency)}
+		}
+		if opt.HasListStartAfter && opt.Concurrency > 1 {
+			return nil, services.PairUnsupportedError{Pair: WithListStartAfter(opt.ListStartAfter)}
+		}
+	}
+	if opt.HasConcurrency && opt.Concurrency > 1 {
+		return NewObjectIterator(ctx, s.nextObjectPageByPrefixParallel, &parallelObjectPageStatus{
+			maxKeys:     input.maxKeys,
+			prefix:      input.prefix,
+			concurrency: opt.Concurrency,
+		}), nil
+	}
+
 	var nextFn NextObjectFunc
 
 	switch {
@@ -273,6 +727,8 @@ func (s *Storage) list(ctx context.Context, path string, opt pairStorageList) (o
 	case opt.ListMode.IsDir():
 		input.delimiter = "/"
 		nextFn = s.nextObjectPageByDir
+	case opt.ListMode.IsPrefix() && listVersions:
+		nextFn = s.nextObjectVersionPageByPrefix
 	case opt.ListMode.IsPrefix():
 		nextFn = s.nextObjectPageByPrefix
 	default:
@@ -292,10 +748,124 @@ func (s *Storage) listMultipart(ctx context.Context, o *Object, opt pairStorageL
 	return NewPartIterator(ctx, s.nextPartPage, input), nil
 }
 
+// listParallel will discover the top-level common prefixes with delimiter and
+// list them concurrently, objects directly under the prefix will be sent as is.
+//
+// Pages of different prefixes are sent as soon as they are listed, so they are
+// interleaved in no particular order, only objects inside a page are sorted.
+// At most concurrency pages will be buffered, workers block until the pages are
+// received or ctx is done.
+func (s *Storage) listParallel(ctx context.Context, input *parallelObjectPageStatus) {
+	defer close(input.pages)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	send := func(v parallelObjectPage) bool {
+		select {
+		case input.pages <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	var once sync.Once
+	fail := func(err error) {
+		once.Do(func() {
+			send(parallelObjectPage{err: err})
+			cancel()
+		})
+	}
+
+	// listPrefix will send the objects of every page, empty pages will be
+	// skipped because ObjectIterator treats them as done.
+	listPrefix := func(prefix, delimiter string, fn func(commonPrefixes []string) bool) error {
+		marker := ""
+		for {
+			// OSS SDK doesn't support context, check it before sending the request.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			options := []oss.Option{
+				oss.Marker(marker),
+				oss.MaxKeys(input.maxKeys),
+				oss.Prefix(prefix),
+			}
+			if delimiter != "" {
+				options = append(options, oss.Delimiter(delimiter))
+			}
+			output, err := s.bucket.ListObjects(options...)
+			if err != nil {
+				return err
+			}
+
+			objects := make([]*Object, 0, len(output.Objects))
+			for _, v := range output.Objects {
+				o, err := s.formatFileObject(v)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, o)
+			}
+			if len(objects) > 0 && !send(parallelObjectPage{objects: objects}) {
+				return ctx.Err()
+			}
+			if fn != nil && !fn(output.CommonPrefixes) {
+				return ctx.Err()
+			}
+
+			if !output.IsTruncated {
+				return nil
+			}
+			marker = output.NextMarker
+		}
+	}
+
+	prefixes := make(chan string)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < input.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for prefix := range prefixes {
+				if err := listPrefix(prefix, "", nil); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+	err := listPrefix(input.prefix, "/", func(commonPrefixes []string) bool {
+		for _, v := range commonPrefixes {
+			select {
+			case prefixes <- v:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		fail(err)
+	}
+	close(prefixes)
+	wg.Wait()
+}
+
 func (s *Storage) metadata(opt pairStorageMetadata) (meta *StorageMeta) {
 	meta = NewStorageMeta()
 	meta.Name = s.bucket.BucketName
 	meta.WorkDir = s.workDir
+	if s.properties != nil {
+		meta.SetLocation(s.properties.Location)
+		setStorageSystemMetadata(meta, StorageSystemMetadata{
+			CreationDate: s.properties.CreationDate,
+			StorageClass: s.properties.StorageClass,
+		})
+	}
 	// set write restriction
 	meta.SetWriteSizeMaximum(writeSizeMaximum)
 	// set append restriction
@@ -307,26 +877,104 @@ func (s *Storage) metadata(opt pairStorageMetadata) (meta *StorageMeta) {
 	return
 }
 
-func (s *Storage) nextObjectPageByDir(ctx context.Context, page *ObjectPage) error {
-	input := page.Status.(*objectPageStatus)
-
-	output, err := s.bucket.ListObjects(
-		oss.Marker(input.marker),
-		oss.MaxKeys(input.maxKeys),
-		oss.Prefix(input.prefix),
-		oss.Delimiter(input.delimiter),
-	)
+func (s *Storage) move(ctx context.Context, src string, dst string, opt pairStorageMove) (err error) {
+	// OSS doesn't support rename, so we need to copy the object and then delete the src.
+	err = s.copy(ctx, src, dst, pairStorageCopy{
+		HasForbidOverwrite:                    opt.HasForbidOverwrite,
+		ForbidOverwrite:                       opt.ForbidOverwrite,
+		HasStorageClass:                       opt.HasStorageClass,
+		StorageClass:                          opt.StorageClass,
+		HasServerSideEncryptionCustomerKey:    opt.HasServerSideEncryptionCustomerKey,
+		ServerSideEncryptionCustomerKey:       opt.ServerSideEncryptionCustomerKey,
+		HasServerSideEncryptionCustomerKeyMd5: opt.HasServerSideEncryptionCustomerKeyMd5,
+		ServerSideEncryptionCustomerKeyMd5:    opt.ServerSideEncryptionCustomerKeyMd5,
+	})
 	if err != nil {
+		return
+	}
+
+	err = s.delete(ctx, src, pairStorageDelete{
+		HasRetryPolicy: opt.HasRetryPolicy,
+		RetryPolicy:    opt.RetryPolicy,
+	})
+	if err != nil {
+		// The dst object has been created, make it clear to user that both src and dst exist now.
+		//
+		// The error is formatted before wrapping, so that it can still be classified.
+		return fmt.Errorf("object copied to %s but delete src failed: %w", dst, formatError(err))
+	}
+	return nil
+}
+
+func (s *Storage) nextObjectPageByDir(ctx context.Context, page *ObjectPage) error {
+	input := page.Status.(*objectPageStatus)
+
+	// The page could be empty after the directory marker is skipped, keep listing
+	// until there is any object, because ObjectIterator treats empty page as done.
+	for len(page.Data) == 0 {
+		// OSS SDK doesn't support context, check it before sending the request.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		output, err := s.bucket.ListObjects(
+			oss.Marker(input.marker),
+			oss.MaxKeys(input.maxKeys),
+			oss.Prefix(input.prefix),
+			oss.Delimiter(input.delimiter),
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range output.CommonPrefixes {
+			o := s.newObject(true)
+			o.ID = v
+			o.Path = s.getRelPath(v)
+			o.Mode |= ModeDir
+
+			page.Data = append(page.Data, o)
+		}
+
+		for _, v := range output.Objects {
+			// The directory marker of the listed dir itself should not be returned as its child.
+			if v.Key == input.prefix && strings.HasSuffix(v.Key, "/") {
+				continue
+			}
+
+			o, err := s.formatFileObject(v)
+			if err != nil {
+				return err
+			}
+
+			page.Data = append(page.Data, o)
+		}
+
+		if !output.IsTruncated {
+			input.done = true
+			return IterateDone
+		}
+
+		input.marker = output.NextMarker
+	}
+	return nil
+}
+
+func (s *Storage) nextObjectPageByPrefix(ctx context.Context, page *ObjectPage) error {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	for _, v := range output.CommonPrefixes {
-		o := s.newObject(true)
-		o.ID = v
-		o.Path = s.getRelPath(v)
-		o.Mode |= ModeDir
+	input := page.Status.(*objectPageStatus)
 
-		page.Data = append(page.Data, o)
+	output, err := s.bucket.ListObjects(
+		oss.Marker(input.marker),
+		oss.MaxKeys(input.maxKeys),
+		oss.Prefix(input.prefix),
+	)
+	if err != nil {
+		return err
 	}
 
 	for _, v := range output.Objects {
@@ -339,6 +987,7 @@ func (s *Storage) nextObjectPageByDir(ctx context.Context, page *ObjectPage) err
 	}
 
 	if !output.IsTruncated {
+		input.done = true
 		return IterateDone
 	}
 
@@ -346,11 +995,47 @@ func (s *Storage) nextObjectPageByDir(ctx context.Context, page *ObjectPage) err
 	return nil
 }
 
-func (s *Storage) nextObjectPageByPrefix(ctx context.Context, page *ObjectPage) error {
+func (s *Storage) nextObjectPageByPrefixParallel(ctx context.Context, page *ObjectPage) error {
+	input := page.Status.(*parallelObjectPageStatus)
+
+	if input.pages == nil {
+		ctx, cancel := context.WithCancel(ctx)
+		input.pages = make(chan parallelObjectPage, input.concurrency)
+		input.cancel = cancel
+		// Workers are bound to the context of the iterator, they will be
+		// stopped once the iteration is done, failed or the context is canceled.
+		go s.listParallel(ctx, input)
+	}
+
+	v, ok := <-input.pages
+	if !ok {
+		input.cancel()
+		// pages will also be closed if the context is done.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return IterateDone
+	}
+	if v.err != nil {
+		input.cancel()
+		return v.err
+	}
+
+	page.Data = append(page.Data, v.objects...)
+	return nil
+}
+
+func (s *Storage) nextObjectVersionPageByPrefix(ctx context.Context, page *ObjectPage) error {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	input := page.Status.(*objectPageStatus)
 
-	output, err := s.bucket.ListObjects(
-		oss.Marker(input.marker),
+	output, err := s.bucket.ListObjectVersions(
+		oss.KeyMarker(input.marker),
+		oss.VersionIdMarker(input.versionIdMarker),
 		oss.MaxKeys(input.maxKeys),
 		oss.Prefix(input.prefix),
 	)
@@ -358,24 +1043,64 @@ func (s *Storage) nextObjectPageByPrefix(ctx context.Context, page *ObjectPage)
 		return err
 	}
 
-	for _, v := range output.Objects {
-		o, err := s.formatFileObject(v)
-		if err != nil {
-			return err
+	for _, v := range output.ObjectVersions {
+		// Create the object as done, or Stat will get the latest version instead.
+		o := s.newObject(true)
+		o.ID = v.Key
+		o.Path = s.getRelPath(v.Key)
+		if v.Type == "Symlink" {
+			o.Mode |= ModeLink
+		} else if strings.HasSuffix(v.Key, "/") {
+			o.Mode |= ModeDir
+		} else {
+			o.Mode |= ModeRead
+		}
+
+		o.SetContentLength(v.Size)
+		o.SetLastModified(v.LastModified.UTC())
+		if v.ETag != "" {
+			o.SetEtag(strings.Trim(v.ETag, "\""))
 		}
+		o.SetSystemMetadata(ObjectSystemMetadata{
+			StorageClass: v.StorageClass,
+			VersionID:    v.VersionId,
+			IsLatest:     v.IsLatest,
+		})
+
+		page.Data = append(page.Data, o)
+	}
+
+	for _, v := range output.ObjectDeleteMarkers {
+		// Delete markers don't have content, so no mode will be set.
+		o := s.newObject(true)
+		o.ID = v.Key
+		o.Path = s.getRelPath(v.Key)
+		o.SetLastModified(v.LastModified.UTC())
+		o.SetSystemMetadata(ObjectSystemMetadata{
+			VersionID:      v.VersionId,
+			IsLatest:       v.IsLatest,
+			IsDeleteMarker: true,
+		})
 
 		page.Data = append(page.Data, o)
 	}
 
 	if !output.IsTruncated {
+		input.done = true
 		return IterateDone
 	}
 
-	input.marker = output.NextMarker
+	input.marker = output.NextKeyMarker
+	input.versionIdMarker = output.NextVersionIdMarker
 	return nil
 }
 
 func (s *Storage) nextPartObjectPageByPrefix(ctx context.Context, page *ObjectPage) error {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	input := page.Status.(*objectPageStatus)
 
 	options := make([]oss.Option, 0, 5)
@@ -396,14 +1121,18 @@ func (s *Storage) nextPartObjectPageByPrefix(ctx context.Context, page *ObjectPa
 		o.Path = s.getRelPath(v.Key)
 		o.Mode |= ModePart
 		o.SetMultipartID(v.UploadID)
+		// Use the initiated time as last modified so that callers can find stale uploads.
+		o.SetLastModified(v.Initiated.UTC())
 
 		page.Data = append(page.Data, o)
 	}
 
 	if output.NextKeyMarker == "" && output.NextUploadIDMarker == "" {
+		input.done = true
 		return IterateDone
 	}
 	if !output.IsTruncated {
+		input.done = true
 		return IterateDone
 	}
 
@@ -413,6 +1142,11 @@ func (s *Storage) nextPartObjectPageByPrefix(ctx context.Context, page *ObjectPa
 }
 
 func (s *Storage) nextPartPage(ctx context.Context, page *PartPage) error {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	input := page.Status.(*partPageStatus)
 
 	imur := oss.InitiateMultipartUploadResult{
@@ -455,207 +1189,1148 @@ func (s *Storage) nextPartPage(ctx context.Context, page *PartPage) error {
 	return nil
 }
 
-func (s *Storage) read(ctx context.Context, path string, w io.Writer, opt pairStorageRead) (n int64, err error) {
-	rp := s.getAbsPath(path)
+func (s *Storage) querySignHTTPDelete(ctx context.Context, path string, expire time.Duration) (req *http.Request, err error) {
+	// No request is sent while signing, but a canceled context should still be respected.
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
-	output, err := s.bucket.GetObject(rp)
+	rp, err := s.getAbsPath(path)
 	if err != nil {
-		return 0, err
+		return
 	}
-	defer output.Close()
 
-	rc := output
-	if opt.HasIoCallback {
-		rc = iowrap.CallbackReadCloser(output, opt.IoCallback)
+	url, err := s.bucket.SignURL(rp, oss.HTTPDelete, int64(expire.Seconds()))
+	if err != nil {
+		return
 	}
 
-	return io.Copy(w, rc)
+	return http.NewRequest(http.MethodDelete, url, nil)
 }
 
-func (s *Storage) stat(ctx context.Context, path string, opt pairStorageStat) (o *Object, err error) {
-	rp := s.getAbsPath(path)
+func (s *Storage) querySignHTTPRead(ctx context.Context, path string, expire time.Duration, opt pairStorageQuerySignHTTPRead) (req *http.Request, err error) {
+	// No request is sent while signing, but a canceled context should still be respected.
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
-	if symlink, err := s.bucket.GetSymlink(rp); err == nil {
-		// The path is a symlink.
-		o = s.newObject(true)
-		o.ID = rp
-		o.Path = path
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
-		target := symlink.Get(oss.HTTPHeaderOssSymlinkTarget)
-		o.SetLinkTarget("/" + target)
+	url, err := s.bucket.SignURL(rp, oss.HTTPGet, int64(expire.Seconds()))
+	if err != nil {
+		return
+	}
 
-		o.Mode |= ModeLink
+	return http.NewRequest(http.MethodGet, url, nil)
+}
 
-		return o, nil
+func (s *Storage) querySignHTTPWrite(ctx context.Context, path string, size int64, expire time.Duration, opt pairStorageQuerySignHTTPWrite) (req *http.Request, err error) {
+	// No request is sent while signing, but a canceled context should still be respected.
+	if err = ctx.Err(); err != nil {
+		return
 	}
 
-	if opt.HasMultipartID {
-		_, err = s.bucket.ListUploadedParts(oss.InitiateMultipartUploadResult{
-			Bucket:   s.bucket.BucketName,
-			Key:      rp,
-			UploadID: opt.MultipartID,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		o = s.newObject(true)
-		o.ID = rp
-		o.Path = path
-		o.Mode |= ModePart
-		o.SetMultipartID(opt.MultipartID)
-		return o, nil
+	if size > writeSizeMaximum {
+		err = fmt.Errorf("size limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
 	}
 
-	if opt.HasObjectMode && opt.ObjectMode.IsDir() {
-		if !s.features.VirtualDir {
-			err = services.PairUnsupportedError{Pair: ps.WithObjectMode(opt.ObjectMode)}
-			return
-		}
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
-		rp += "/"
+	url, err := s.bucket.SignURL(rp, oss.HTTPPut, int64(expire.Seconds()))
+	if err != nil {
+		return
 	}
 
-	output, err := s.bucket.GetObjectMeta(rp)
+	req, err = http.NewRequest(http.MethodPut, url, nil)
 	if err != nil {
-		return nil, err
+		return
 	}
+	req.ContentLength = size
 
-	o = s.newObject(true)
-	o.ID = rp
-	o.Path = path
-	if opt.HasObjectMode && opt.ObjectMode.IsDir() {
-		o.Mode |= ModeDir
-	} else {
-		o.Mode |= ModeRead
+	return req, nil
+}
+
+func (s *Storage) read(ctx context.Context, path string, w io.Writer, opt pairStorageRead) (n int64, err error) {
+	if opt.HasTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
 	}
 
-	if v := output.Get(headers.ContentLength); v != "" {
-		size, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		o.SetContentLength(size)
+	if opt.HasTrafficLimit && (opt.TrafficLimit < trafficLimitMinimum || opt.TrafficLimit > trafficLimitMaximum) {
+		err = services.PairUnsupportedError{Pair: WithTrafficLimit(opt.TrafficLimit)}
+		return
+	}
+	// A negative offset means reading the last -offset bytes, which already
+	// determines the size.
+	if opt.HasOffset && opt.Offset < 0 && opt.HasSize && opt.Size > 0 {
+		err = services.PairUnsupportedError{Pair: ps.WithSize(opt.Size)}
+		return
 	}
 
-	if v := output.Get(headers.LastModified); v != "" {
-		lastModified, err := time.Parse(time.RFC1123, v)
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+	if opt.HasFollowSymlink && opt.FollowSymlink {
+		rp, err = s.resolveSymlink(rp)
 		if err != nil {
-			return nil, err
+			return
 		}
-		o.SetLastModified(lastModified)
 	}
 
-	// OSS advise us don't use Etag as Content-MD5.
-	//
-	// ref: https://help.aliyun.com/document_detail/31965.html
-	if v := output.Get(headers.ETag); v != "" {
-		o.SetEtag(v)
+	options := make([]oss.Option, 0, 2)
+	if opt.HasProgressCallback {
+		options = append(options, oss.Progress(progressListener(opt.ProgressCallback)))
 	}
-
-	if v := output.Get(headers.ContentType); v != "" {
-		o.SetContentType(v)
+	if opt.HasTrafficLimit {
+		options = append(options, oss.TrafficLimitHeader(opt.TrafficLimit))
 	}
-
-	var sm ObjectSystemMetadata
-	if v := output.Get(storageClassHeader); v != "" {
-		sm.StorageClass = v
+	if opt.HasIfMatch {
+		options = append(options, oss.IfMatch(opt.IfMatch))
 	}
-	if v := output.Get(serverSideEncryptionHeader); v != "" {
-		sm.ServerSideEncryption = v
+	if opt.HasIfNoneMatch {
+		options = append(options, oss.IfNoneMatch(opt.IfNoneMatch))
 	}
-	if v := output.Get(serverSideEncryptionKeyIdHeader); v != "" {
-		sm.ServerSideEncryptionKeyID = v
+	if opt.HasIfModifiedSince {
+		options = append(options, oss.IfModifiedSince(opt.IfModifiedSince))
 	}
-	o.SetSystemMetadata(sm)
-
-	return o, nil
-}
-
-func (s *Storage) write(ctx context.Context, path string, r io.Reader, size int64, opt pairStorageWrite) (n int64, err error) {
-	if size > writeSizeMaximum {
-		err = fmt.Errorf("size limit exceeded: %w", services.ErrRestrictionDissatisfied)
-		return
+	if opt.HasIfUnmodifiedSince {
+		options = append(options, oss.IfUnmodifiedSince(opt.IfUnmodifiedSince))
+	}
+	if opt.HasVersionID {
+		options = append(options, oss.VersionId(opt.VersionID))
+	}
+	if opt.HasImageProcess {
+		options = append(options, oss.Process(opt.ImageProcess))
 	}
+	if opt.HasServerSideEncryptionCustomerKey || opt.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err := formatServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return 0, err
+		}
+		options = append(options, sseOptions...)
+	}
+	// A zero size means reading from offset to the end of the object.
+	if opt.HasOffset && opt.Offset < 0 {
+		// Suffix range `bytes=-N` reads the last N bytes, the whole object will
+		// be returned if it's shorter than N.
+		options = append(options, oss.NormalizedRange(fmt.Sprintf("-%d", -opt.Offset)))
+	} else if opt.HasSize && opt.Size > 0 {
+		options = append(options, oss.Range(opt.Offset, opt.Offset+opt.Size-1))
+	} else if opt.HasOffset && opt.Offset > 0 {
+		options = append(options, oss.NormalizedRange(fmt.Sprintf("%d-", opt.Offset)))
+	}
+
+	var output *oss.GetObjectResult
+	// read_after_write retries NotFound for objects which were just written,
+	// the errors retried by retry_policy will be retried in every attempt.
+	err = s.retryIf(ctx, opt.ReadAfterWrite, isNotFoundError, func() error {
+		return s.retry(ctx, opt.RetryPolicy, func() (err error) {
+			output, err = s.bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: rp}, options)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer output.Response.Close()
 
-	// According to GSP-751, we should allow the user to pass in a nil io.Reader.
-	// Since oss supports reader passed in as nil, we do not need to determine the case where the reader is nil and the size is 0.
-	// ref: https://github.com/beyondstorage/go-storage/blob/master/docs/rfcs/751-write-empty-file-behavior.md
-	if r == nil && size != 0 {
-		return 0, fmt.Errorf("reader is nil but size is not 0")
-	} else {
-		r = io.LimitReader(r, size)
+	// GET object returns the same headers as HEAD object, so that callers can
+	// get the system metadata without an extra Stat.
+	if opt.HasObjectMetadataCallback {
+		opt.ObjectMetadataCallback(formatSystemMetadata(output.Response.Headers))
 	}
 
+	var rc io.ReadCloser = output.Response
 	if opt.HasIoCallback {
-		r = iowrap.CallbackReader(r, opt.IoCallback)
+		rc = iowrap.CallbackReadCloser(rc, opt.IoCallback)
 	}
 
-	rp := s.getAbsPath(path)
+	// Stop reading once the context is done, the download will be aborted while closing output.
+	return io.Copy(w, contextReader{ctx: ctx, r: rc})
+}
 
-	options := make([]oss.Option, 0, 3)
-	options = append(options, oss.ContentLength(size))
-	if opt.HasContentMd5 {
-		options = append(options, oss.ContentMD5(opt.ContentMd5))
-	}
-	if opt.HasStorageClass {
-		options = append(options, oss.StorageClass(oss.StorageClassType(opt.StorageClass)))
-	}
-	if opt.HasServerSideEncryption {
-		options = append(options, oss.ServerSideEncryption(opt.ServerSideEncryption))
-	}
-	if opt.HasServerSideDataEncryption {
-		options = append(options, oss.ServerSideDataEncryption(opt.ServerSideDataEncryption))
-	}
-	if opt.HasServerSideEncryptionKeyID {
-		options = append(options, oss.ServerSideEncryptionKeyID(opt.ServerSideEncryptionKeyID))
+func (s *Storage) readFile(ctx context.Context, path string, filePath string, opt pairStorageReadFile) (n int64, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
 	}
 
-	err = s.bucket.PutObject(rp, r, options...)
+	rp, err := s.getAbsPath(path)
 	if err != nil {
 		return
 	}
-	return size, nil
-}
-
-func (s *Storage) writeAppend(ctx context.Context, o *Object, r io.Reader, size int64, opt pairStorageWriteAppend) (n int64, err error) {
-	rp := o.GetID()
 
-	if opt.HasIoCallback {
-		r = iowrap.CallbackReader(r, opt.IoCallback)
+	tempDir := filepath.Dir(filePath)
+	if opt.HasTempDir {
+		err = os.MkdirAll(opt.TempDir, 0755)
+		if err != nil {
+			return
+		}
+		tempDir = opt.TempDir
 	}
-
-	offset, _ := o.GetAppendOffset()
+	// Reserve a unique name for the temporary file, OSS SDK will overwrite it.
+	f, err := ioutil.TempFile(tempDir, filepath.Base(filePath)+".*")
+	if err != nil {
+		return
+	}
+	tempPath := f.Name()
+	_ = f.Close()
+	defer func() {
+		if err != nil {
+			// OSS SDK writes into tempPath with the suffix, and renames it to tempPath once finished.
+			_ = os.Remove(tempPath + oss.TempFileSuffix)
+			_ = os.Remove(tempPath)
+		}
+	}()
 
 	options := make([]oss.Option, 0, 1)
-	options = append(options, oss.ContentLength(size))
-	if opt.HasContentMd5 {
-		options = append(options, oss.ContentMD5(opt.ContentMd5))
+	if opt.HasVersionID {
+		options = append(options, oss.VersionId(opt.VersionID))
 	}
 
-	offset, err = s.bucket.AppendObject(rp, r, offset, options...)
+	err = s.bucket.GetObjectToFile(rp, tempPath, options...)
 	if err != nil {
 		return
 	}
 
-	o.SetAppendOffset(offset)
-
-	return size, err
-}
-
-func (s *Storage) writeMultipart(ctx context.Context, o *Object, r io.Reader, size int64, index int, opt pairStorageWriteMultipart) (n int64, part *Part, err error) {
-	if index < 0 || index >= multipartNumberMaximum {
-		err = fmt.Errorf("multipart number limit exceeded: %w", services.ErrRestrictionDissatisfied)
+	fi, err := os.Stat(tempPath)
+	if err != nil {
 		return
 	}
-	if size > multipartSizeMaximum {
-		err = fmt.Errorf("size limit exceeded: %w", services.ErrRestrictionDissatisfied)
+	err = moveFile(tempPath, filePath)
+	if err != nil {
 		return
 	}
+	return fi.Size(), nil
+}
 
-	imur := oss.InitiateMultipartUploadResult{
-		Bucket:   s.bucket.BucketName,
-		Key:      o.ID,
+// resolveSymlink will follow the symlink chain starting from rp, and return the
+// key of the final object which is not a symlink.
+func (s *Storage) resolveSymlink(rp string) (string, error) {
+	for i := 0; i <= symlinkDepthMaximum; i++ {
+		symlink, err := s.bucket.GetSymlink(rp)
+		if err != nil {
+			// Not a symlink or not exist, leave it to the caller.
+			if checkError(err, responseCodeNotSymlink) || checkError(err, responseCodeNoSuchKey) {
+				return rp, nil
+			}
+			return "", err
+		}
+		rp = symlink.Get(oss.HTTPHeaderOssSymlinkTarget)
+	}
+	return "", fmt.Errorf("too many levels of symlinks, at most %d: %w", symlinkDepthMaximum, services.ErrRestrictionDissatisfied)
+}
+
+func (s *Storage) restore(ctx context.Context, path string, opt pairStorageRestore) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	// OSS SDK will use 1 day and Standard tier if they are not set.
+	config := oss.RestoreConfiguration{}
+	if opt.HasRestoreDays {
+		config.Days = int32(opt.RestoreDays)
+	}
+	if opt.HasRestoreTier {
+		config.Tier = opt.RestoreTier
+	}
+
+	return s.bucket.RestoreObjectDetail(rp, config)
+}
+
+func (s *Storage) selectObject(ctx context.Context, path string, sql string, opt pairStorageSelectObject) (rc io.ReadCloser, err error) {
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	// OSS only supports CSV to CSV and JSON to JSON, so the output format follows the input.
+	req := oss.SelectRequest{
+		Expression: sql,
+	}
+	if opt.HasSelectCompressionType {
+		req.InputSerializationSelect.CompressionType = opt.SelectCompressionType
+	}
+	if opt.HasSelectJSONType {
+		if opt.HasSelectCsvFileHeaderInfo || opt.HasSelectCsvRecordDelimiter || opt.HasSelectCsvFieldDelimiter {
+			err = services.PairUnsupportedError{Pair: WithSelectJSONType(opt.SelectJSONType)}
+			return
+		}
+
+		req.InputSerializationSelect.JsonBodyInput.JSONType = opt.SelectJSONType
+		if opt.HasSelectJSONRecordDelimiter {
+			req.OutputSerializationSelect.JsonBodyOutput.RecordDelimiter = opt.SelectJSONRecordDelimiter
+		}
+	} else {
+		if opt.HasSelectJSONRecordDelimiter {
+			err = services.PairUnsupportedError{Pair: WithSelectJSONRecordDelimiter(opt.SelectJSONRecordDelimiter)}
+			return
+		}
+		if opt.HasSelectCsvFileHeaderInfo {
+			req.InputSerializationSelect.CsvBodyInput.FileHeaderInfo = opt.SelectCsvFileHeaderInfo
+		}
+		if opt.HasSelectCsvRecordDelimiter {
+			req.InputSerializationSelect.CsvBodyInput.RecordDelimiter = opt.SelectCsvRecordDelimiter
+			req.OutputSerializationSelect.CsvBodyOutput.RecordDelimiter = opt.SelectCsvRecordDelimiter
+		}
+		if opt.HasSelectCsvFieldDelimiter {
+			req.InputSerializationSelect.CsvBodyInput.FieldDelimiter = opt.SelectCsvFieldDelimiter
+			req.OutputSerializationSelect.CsvBodyOutput.FieldDelimiter = opt.SelectCsvFieldDelimiter
+		}
+	}
+
+	return s.bucket.SelectObject(rp, req)
+}
+
+func (s *Storage) setObjectACL(ctx context.Context, path string, acl string) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	switch acl {
+	case ObjectACLDefault, ObjectACLPrivate, ObjectACLPublicRead, ObjectACLPublicReadWrite:
+	default:
+		return fmt.Errorf("object acl %q is invalid: %w", acl, services.ErrRestrictionDissatisfied)
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	return s.bucket.SetObjectACL(rp, oss.ACLType(acl))
+}
+
+func (s *Storage) stat(ctx context.Context, path string, opt pairStorageStat) (o *Object, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	// Fast stat only checks existence and size, so symlink, tagging and ACL will not be fetched.
+	fastStat := opt.HasFastStat && opt.FastStat
+
+	if !fastStat {
+		symlink, err := s.bucket.GetSymlink(rp)
+		if err != nil && !checkError(err, responseCodeNotSymlink) && !checkError(err, responseCodeNoSuchKey) {
+			return nil, err
+		}
+		if err == nil {
+			// The path is a symlink.
+			o = s.newObject(true)
+			o.ID = rp
+			o.Path = path
+
+			target := symlink.Get(oss.HTTPHeaderOssSymlinkTarget)
+			o.SetLinkTarget("/" + target)
+
+			o.Mode |= ModeLink
+
+			return o, nil
+		}
+	}
+
+	if opt.HasMultipartID {
+		_, err = s.bucket.ListUploadedParts(oss.InitiateMultipartUploadResult{
+			Bucket:   s.bucket.BucketName,
+			Key:      rp,
+			UploadID: opt.MultipartID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		o = s.newObject(true)
+		o.ID = rp
+		o.Path = path
+		o.Mode |= ModePart
+		o.SetMultipartID(opt.MultipartID)
+		return o, nil
+	}
+
+	if opt.HasObjectMode && opt.ObjectMode.IsDir() {
+		if !s.features.VirtualDir {
+			err = services.PairUnsupportedError{Pair: ps.WithObjectMode(opt.ObjectMode)}
+			return
+		}
+
+		if !strings.HasSuffix(rp, "/") {
+			rp += "/"
+		}
+	}
+
+	// GetObjectMeta only returns ETag, Content-Length and Last-Modified, so we
+	// need GetObjectDetailedMeta (HEAD) to get content type and storage class.
+	//
+	// ref: https://help.aliyun.com/document_detail/31984.html
+	options := make([]oss.Option, 0, 1)
+	if opt.HasVersionID {
+		options = append(options, oss.VersionId(opt.VersionID))
+	}
+	if opt.HasServerSideEncryptionCustomerKey || opt.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err := formatServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sseOptions...)
+	}
+
+	head := func(key string) (output http.Header, err error) {
+		err = s.retry(ctx, opt.RetryPolicy, func() (err error) {
+			if fastStat {
+				output, err = s.bucket.GetObjectMeta(key, options...)
+			} else {
+				output, err = s.bucket.GetObjectDetailedMeta(key, options...)
+			}
+			return err
+		})
+		return
+	}
+
+	var output http.Header
+	// read_after_write retries NotFound for objects which were just written.
+	err = s.retryIf(ctx, opt.ReadAfterWrite, isNotFoundError, func() (err error) {
+		output, err = head(rp)
+		return err
+	})
+	// The path may refer to a directory marker created by CreateDir, try the
+	// key with trailing slash before reporting the object not exist.
+	if err != nil && s.features.VirtualDir && !strings.HasSuffix(rp, "/") && isNotFoundError(err) {
+		if dirOutput, dirErr := head(rp + "/"); dirErr == nil {
+			output, err = dirOutput, nil
+			rp += "/"
+		}
+	}
+	if err != nil {
+		// HEAD responses have no body, so NoSuchBucket can't be told from NoSuchKey.
+		if isNotFoundError(err) {
+			if bucketErr := s.checkBucketExist(); bucketErr != nil {
+				return nil, bucketErr
+			}
+		}
+		return nil, err
+	}
+
+	o = s.newObject(true)
+	o.ID = rp
+	o.Path = path
+	// Keys ending with "/" are directory markers created by CreateDir.
+	if strings.HasSuffix(rp, "/") {
+		o.Mode |= ModeDir
+	} else {
+		o.Mode |= ModeRead
+	}
+
+	if v := output.Get(headers.ContentLength); v != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		o.SetContentLength(size)
+	}
+
+	if v := output.Get(headers.LastModified); v != "" {
+		lastModified, err := formatLastModified(v)
+		if err != nil {
+			return nil, err
+		}
+		o.SetLastModified(lastModified)
+	}
+
+	// OSS advise us don't use Etag as Content-MD5.
+	//
+	// ref: https://help.aliyun.com/document_detail/31965.html
+	// ETag of multipart or appendable objects has a dash suffix, keep it as is
+	// and only strip the surrounding quotes.
+	if v := output.Get(headers.ETag); v != "" {
+		o.SetEtag(strings.Trim(v, "\""))
+	}
+
+	if v := output.Get(headers.ContentType); v != "" {
+		o.SetContentType(v)
+	}
+
+	if um := formatUserMetadata(output); um != nil {
+		o.SetUserMetadata(um)
+	}
+	if fastStat {
+		return o, nil
+	}
+
+	sm := formatSystemMetadata(output)
+	// HEAD object only returns the count of tags, so we need to get them only when the object has tags.
+	if v := output.Get(objectTaggingCountHeader); v != "" && v != "0" {
+		tagging, err := s.bucket.GetObjectTagging(rp, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		sm.ObjectTagging = make(map[string]string, len(tagging.Tags))
+		for _, tag := range tagging.Tags {
+			sm.ObjectTagging[tag.Key] = tag.Value
+		}
+	}
+	// HEAD object doesn't return the object ACL, we have to get it separately.
+	if opt.HasStatObjectACL && opt.StatObjectACL {
+		acl, err := s.bucket.GetObjectACL(rp, options...)
+		if err != nil {
+			return nil, err
+		}
+		sm.ObjectACL = acl.ACL
+	}
+	o.SetSystemMetadata(sm)
+
+	return o, nil
+}
+
+func (s *Storage) updateMeta(ctx context.Context, path string, opt pairStorageUpdateMeta) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	// The object encrypted with SSE-C can only be read with the same key.
+	var sseOptions, srcSSEOptions []oss.Option
+	if opt.HasServerSideEncryptionCustomerKey || opt.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err = formatServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return
+		}
+		srcSSEOptions, err = formatCopySourceServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return
+		}
+	}
+
+	meta, err := s.bucket.GetObjectDetailedMeta(rp, sseOptions...)
+	if err != nil {
+		return
+	}
+
+	size, err := strconv.ParseInt(meta.Get(headers.ContentLength), 10, 64)
+	if err != nil {
+		return
+	}
+
+	// The ACL is not returned by HEAD, and will be reset by the copy.
+	acl, err := s.bucket.GetObjectACL(rp)
+	if err != nil {
+		return
+	}
+
+	// REPLACE drops all metadata not carried in the request, so carry over the
+	// metadata of the object first and let the pairs override them.
+	if opt.HasUserMetadata {
+		meta = meta.Clone()
+		for k := range meta {
+			if strings.HasPrefix(strings.ToLower(k), userMetadataPrefix) {
+				meta.Del(k)
+			}
+		}
+	}
+	options := formatSourceMetadataOptions(meta)
+	options = append(options, formatCopyMetadataOptions(pairStorageCopy{
+		HasCacheControl:       opt.HasCacheControl,
+		CacheControl:          opt.CacheControl,
+		HasContentDisposition: opt.HasContentDisposition,
+		ContentDisposition:    opt.ContentDisposition,
+		HasContentEncoding:    opt.HasContentEncoding,
+		ContentEncoding:       opt.ContentEncoding,
+		HasContentType:        opt.HasContentType,
+		ContentType:           opt.ContentType,
+		HasExpires:            opt.HasExpires,
+		Expires:               opt.Expires,
+		HasUserMetadata:       opt.HasUserMetadata,
+		UserMetadata:          opt.UserMetadata,
+	})...)
+	// Keep the storage class and encryption, or the object will be copied with the bucket's default ones.
+	if v := meta.Get(storageClassHeader); v != "" {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(v)))
+	}
+	if sseOptions != nil {
+		options = append(options, sseOptions...)
+	} else {
+		options = append(options, formatSourceServerSideEncryptionOptions(meta)...)
+	}
+
+	// CopyObject can only copy objects smaller than 1GB, larger objects need to be copied by UploadPartCopy.
+	if size > copySizeMaximum {
+		err = s.copyMultipart(rp, rp, size, meta.Get(oss.HTTPHeaderEtag), options, pairStorageCopy{
+			HasServerSideEncryptionCustomerKey:    opt.HasServerSideEncryptionCustomerKey,
+			ServerSideEncryptionCustomerKey:       opt.ServerSideEncryptionCustomerKey,
+			HasServerSideEncryptionCustomerKeyMd5: opt.HasServerSideEncryptionCustomerKeyMd5,
+			ServerSideEncryptionCustomerKeyMd5:    opt.ServerSideEncryptionCustomerKeyMd5,
+		})
+		if err != nil {
+			return fmt.Errorf("object is kept unchanged as multipart copy failed: %w", formatError(err))
+		}
+		// InitiateMultipartUpload doesn't accept ACL, set it after the object has been replaced.
+		if acl.ACL != ObjectACLDefault {
+			err = s.bucket.SetObjectACL(rp, oss.ACLType(acl.ACL))
+			if err != nil {
+				return fmt.Errorf("object is updated but restore acl %s failed: %w", acl.ACL, formatError(err))
+			}
+		}
+		return nil
+	}
+
+	if acl.ACL != ObjectACLDefault {
+		options = append(options, oss.ObjectACL(oss.ACLType(acl.ACL)))
+	}
+	options = append(options, srcSSEOptions...)
+	options = append(options, oss.MetadataDirective(oss.MetaReplace))
+	_, err = s.bucket.CopyObject(rp, rp, options...)
+	return
+}
+
+func (s *Storage) uploadFile(ctx context.Context, path string, filePath string, opt pairStorageUploadFile) (err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	var partSize int64
+	if opt.HasPartSize {
+		partSize = opt.PartSize
+	} else {
+		// Pick a part size by the file size, so that large files will not exceed the part count limit.
+		fi, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		partSize, err = CalculatePartSize(fi.Size())
+		if err != nil {
+			return err
+		}
+	}
+	if partSize < multipartSizeMinimum || partSize > multipartSizeMaximum {
+		err = fmt.Errorf("part size %d is out of range [%d, %d]: %w",
+			partSize, multipartSizeMinimum, multipartSizeMaximum, services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	options := make([]oss.Option, 0, 4)
+	if opt.HasConcurrency && opt.Concurrency > 0 {
+		options = append(options, oss.Routines(opt.Concurrency))
+	}
+	if opt.HasCheckpointDir {
+		// OSS SDK will not create the checkpoint dir.
+		err = os.MkdirAll(opt.CheckpointDir, 0755)
+		if err != nil {
+			return
+		}
+		options = append(options, oss.CheckpointDir(true, opt.CheckpointDir))
+	}
+	if opt.HasContentType {
+		options = append(options, oss.ContentType(opt.ContentType))
+	}
+	if opt.HasStorageClass {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opt.StorageClass)))
+	}
+
+	return s.bucket.UploadFile(rp, filePath, partSize, options...)
+}
+
+// uploadMultipart will upload r part by part, every part will be retried by policy.
+func (s *Storage) uploadMultipart(ctx context.Context, path string, r io.Reader, partSize int64, concurrency int, policy RetryPolicy, opt pairStorageCreateMultipart) (n int64, err error) {
+	if partSize < multipartSizeMinimum || partSize > multipartSizeMaximum {
+		err = fmt.Errorf("part size %d is out of range [%d, %d]: %w",
+			partSize, multipartSizeMinimum, multipartSizeMaximum, services.ErrRestrictionDissatisfied)
+		return
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	o, err := s.createMultipart(ctx, path, opt)
+	if err != nil {
+		return
+	}
+	// Parts must be encrypted with the same customer-provided key as the multipart upload.
+	wopt := pairStorageWriteMultipart{
+		HasServerSideEncryptionCustomerKey:    opt.HasServerSideEncryptionCustomerKey,
+		ServerSideEncryptionCustomerKey:       opt.ServerSideEncryptionCustomerKey,
+		HasServerSideEncryptionCustomerKeyMd5: opt.HasServerSideEncryptionCustomerKeyMd5,
+		ServerSideEncryptionCustomerKeyMd5:    opt.ServerSideEncryptionCustomerKeyMd5,
+	}
+	// Abort the multipart upload so that uploaded parts will not be charged.
+	defer func() {
+		if err == nil {
+			return
+		}
+		_ = s.bucket.AbortMultipartUpload(oss.InitiateMultipartUploadResult{
+			Bucket:   s.bucket.BucketName,
+			Key:      o.ID,
+			UploadID: o.MustGetMultipartID(),
+		})
+	}()
+
+	uctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		parts    []*Part
+		firstErr error
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	// Parts of seekable readers like *os.File are read via io.SectionReader,
+	// so that they don't need to be buffered in memory.
+	ra, seekable := r.(io.ReaderAt)
+	var offset, end int64
+	if seekable {
+		if seeker, ok := r.(io.Seeker); !ok {
+			seekable = false
+		} else if offset, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return
+		} else if end, err = seeker.Seek(0, io.SeekEnd); err != nil {
+			return
+		}
+	}
+	// Buffered parts are capped to avoid allocating partSize * concurrency bytes
+	// for large parts.
+	if !seekable && partSize*int64(concurrency) > multipartBufferMaximum {
+		concurrency = int(multipartBufferMaximum / partSize)
+		if concurrency == 0 {
+			concurrency = 1
+		}
+	}
+	// next returns the reader of the next part, last will be true if r has been drained.
+	next := func() (pr io.ReadSeeker, size int64, last bool, err error) {
+		if seekable {
+			size = partSize
+			if end-offset <= size {
+				size, last = end-offset, true
+			}
+			pr = io.NewSectionReader(ra, offset, size)
+			offset += size
+			return pr, size, last, nil
+		}
+
+		buf := make([]byte, partSize)
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return bytes.NewReader(buf[:n]), int64(n), true, nil
+		}
+		return bytes.NewReader(buf[:n]), int64(n), false, err
+	}
+
+	// sem limits the running uploads, and the buffered parts as well.
+	sem := make(chan struct{}, concurrency)
+	for index := 0; ; index++ {
+		select {
+		case sem <- struct{}{}:
+		case <-uctx.Done():
+		}
+		if uctx.Err() != nil {
+			break
+		}
+
+		pr, size, last, rerr := next()
+		if rerr != nil {
+			<-sem
+			setErr(rerr)
+			break
+		}
+		// An empty part is still required for an empty reader to complete the upload.
+		if size == 0 && index > 0 {
+			<-sem
+			break
+		}
+		if index >= multipartNumberMaximum {
+			<-sem
+			setErr(fmt.Errorf("multipart number limit exceeded: %w", services.ErrRestrictionDissatisfied))
+			break
+		}
+
+		wg.Add(1)
+		go func(index int, pr io.ReadSeeker, size int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			var part *Part
+			err := s.retry(uctx, policy, func() (err error) {
+				// Rewind the part for every attempt.
+				if _, err = pr.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				_, part, err = s.writeMultipart(uctx, o, pr, size, index, wopt)
+				return err
+			})
+			if err != nil {
+				setErr(err)
+				return
+			}
+
+			mu.Lock()
+			parts = append(parts, part)
+			mu.Unlock()
+		}(index, pr, size)
+
+		n += size
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	// The parent context is done, the upload is not finished.
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Index < parts[j].Index
+	})
+	err = s.completeMultipart(ctx, o, parts, pairStorageCompleteMultipart{
+		HasForbidOverwrite: opt.HasForbidOverwrite,
+		ForbidOverwrite:    opt.ForbidOverwrite,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Storage) write(ctx context.Context, path string, r io.Reader, size int64, opt pairStorageWrite) (n int64, err error) {
+	if opt.HasTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
+	}
+
+	if size > writeSizeMaximum {
+		err = fmt.Errorf("size limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	sseOptions, err := formatServerSideEncryptionOptions(serverSideEncryptionPairs{
+		HasServerSideEncryption:               opt.HasServerSideEncryption,
+		ServerSideEncryption:                  opt.ServerSideEncryption,
+		HasServerSideDataEncryption:           opt.HasServerSideDataEncryption,
+		ServerSideDataEncryption:              opt.ServerSideDataEncryption,
+		HasServerSideEncryptionKeyID:          opt.HasServerSideEncryptionKeyID,
+		ServerSideEncryptionKeyID:             opt.ServerSideEncryptionKeyID,
+		HasServerSideEncryptionCustomerKey:    opt.HasServerSideEncryptionCustomerKey,
+		ServerSideEncryptionCustomerKey:       opt.ServerSideEncryptionCustomerKey,
+		HasServerSideEncryptionCustomerKeyMd5: opt.HasServerSideEncryptionCustomerKeyMd5,
+		ServerSideEncryptionCustomerKeyMd5:    opt.ServerSideEncryptionCustomerKeyMd5,
+	})
+	if err != nil {
+		return
+	}
+	if opt.HasTrafficLimit && (opt.TrafficLimit < trafficLimitMinimum || opt.TrafficLimit > trafficLimitMaximum) {
+		err = services.PairUnsupportedError{Pair: WithTrafficLimit(opt.TrafficLimit)}
+		return
+	}
+
+	// According to GSP-751, we should allow the user to pass in a nil io.Reader.
+	// Since oss supports reader passed in as nil, we do not need to determine the case where the reader is nil and the size is 0.
+	// ref: https://github.com/beyondstorage/go-storage/blob/master/docs/rfcs/751-write-empty-file-behavior.md
+	if r == nil && size != 0 {
+		return 0, fmt.Errorf("reader is nil but size is not 0")
+	}
+	// Negative size means the size is unknown, and r will be read until EOF.
+	if size < 0 {
+		return s.writeStream(ctx, path, r, opt)
+	}
+
+	// The request can only be retried while r can be rewound to where it starts.
+	var policy RetryPolicy
+	var start int64
+	seeker, rewindable := r.(io.Seeker)
+	rewindable = rewindable && opt.HasRetryPolicy
+	if rewindable {
+		start, err = seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return
+		}
+		policy = opt.RetryPolicy
+	}
+
+	// sizeErr will be set if r ends before size bytes have been read.
+	var sizeErr error
+
+	// newBody will wrap r for every attempt.
+	newBody := func() io.Reader {
+		// For size 0, send no body at all so that `Content-Length: 0` is set and
+		// r will never be read.
+		if size == 0 {
+			return nil
+		}
+
+		var body io.Reader = &sizeCheckReader{r: io.LimitReader(r, size), size: size, err: &sizeErr}
+		if opt.HasIoCallback {
+			body = iowrap.CallbackReader(body, opt.IoCallback)
+		}
+		// Stop reading once the context is done, so that the upload will be aborted.
+		body = contextReader{ctx: ctx, r: body}
+		// OSS SDK can only get the content length from a few reader types like
+		// io.LimitedReader, otherwise the body will be sent in chunked encoding.
+		return io.LimitReader(body, size)
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	options := make([]oss.Option, 0, 3)
+	options = append(options, oss.ContentLength(size))
+	if opt.HasProgressCallback {
+		options = append(options, oss.Progress(progressListener(opt.ProgressCallback)))
+	}
+	if opt.HasContentMd5 {
+		options = append(options, oss.ContentMD5(opt.ContentMd5))
+	}
+	// Leave an empty content type to OSS SDK, which will detect it by the object's extension.
+	if opt.HasContentType && opt.ContentType != "" {
+		options = append(options, oss.ContentType(opt.ContentType))
+	}
+	if opt.HasStorageClass {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opt.StorageClass)))
+	}
+	options = append(options, sseOptions...)
+	if opt.HasObjectTagging && len(opt.ObjectTagging) > 0 {
+		options = append(options, oss.SetTagging(formatObjectTagging(opt.ObjectTagging)))
+	}
+	if opt.HasObjectACL {
+		options = append(options, oss.ObjectACL(oss.ACLType(opt.ObjectACL)))
+	}
+	if opt.HasTrafficLimit {
+		options = append(options, oss.TrafficLimitHeader(opt.TrafficLimit))
+	}
+	if opt.HasCacheControl {
+		options = append(options, oss.CacheControl(opt.CacheControl))
+	}
+	if opt.HasContentDisposition {
+		options = append(options, oss.ContentDisposition(opt.ContentDisposition))
+	}
+	if opt.HasContentEncoding {
+		options = append(options, oss.ContentEncoding(opt.ContentEncoding))
+	}
+	if opt.HasExpires {
+		// http.TimeFormat requires the time to be in UTC.
+		options = append(options, oss.Expires(opt.Expires.UTC()))
+	}
+	for k, v := range opt.UserMetadata {
+		options = append(options, oss.Meta(strings.ToLower(k), encodeUserMetadataValue(v)))
+	}
+
+	if opt.HasForbidOverwrite {
+		options = append(options, oss.ForbidOverWrite(opt.ForbidOverwrite))
+	}
+	if opt.HasCallback {
+		options = append(options, oss.Callback(opt.Callback))
+	}
+	if opt.HasCallbackVar {
+		options = append(options, oss.CallbackVar(opt.CallbackVar))
+	}
+
+	// PutObject will drop the response body, so we use DoPutObject to get the callback result.
+	var resp *oss.Response
+	err = s.retry(ctx, policy, func() (err error) {
+		if rewindable {
+			_, err = seeker.Seek(start, io.SeekStart)
+			if err != nil {
+				return err
+			}
+		}
+
+		resp, err = s.bucket.DoPutObject(&oss.PutObjectRequest{
+			ObjectKey: rp,
+			Reader:    newBody(),
+		}, options)
+		// The error of sizeCheckReader may be wrapped by net/http, and it can't
+		// be fixed by retrying.
+		if err != nil && sizeErr != nil {
+			return sizeErr
+		}
+		return err
+	})
+	if err != nil {
+		// The body of the response with mismatched CRC64 is returned as well.
+		if resp != nil {
+			resp.Body.Close()
+		}
+		// The error of contextReader may be wrapped by net/http, return the context error directly.
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if opt.HasCallback && opt.HasCallbackResult {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		opt.CallbackResult(body)
+	}
+
+	if opt.HasObjectMetadataCallback {
+		opt.ObjectMetadataCallback(formatSystemMetadata(resp.Headers))
+	}
+	return size, nil
+}
+
+func (s *Storage) writeAppend(ctx context.Context, o *Object, r io.Reader, size int64, opt pairStorageWriteAppend) (n int64, err error) {
+	rp := o.GetID()
+
+	if opt.HasIoCallback {
+		r = iowrap.CallbackReader(r, opt.IoCallback)
+	}
+
+	offset, _ := o.GetAppendOffset()
+	if offset+size > appendTotalSizeMaximum {
+		err = fmt.Errorf("append total size limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	options := make([]oss.Option, 0, 1)
+	options = append(options, oss.ContentLength(size))
+	if opt.HasContentMd5 {
+		options = append(options, oss.ContentMD5(opt.ContentMd5))
+	}
+	if opt.HasProgressCallback {
+		options = append(options, oss.Progress(progressListener(opt.ProgressCallback)))
+	}
+
+	offset, err = s.bucket.AppendObject(rp, r, offset, options...)
+	if err != nil {
+		return
+	}
+
+	o.SetAppendOffset(offset)
+
+	return size, err
+}
+
+func (s *Storage) writeFile(ctx context.Context, path string, filePath string, opt pairStorageWriteFile) (n int64, err error) {
+	// OSS SDK doesn't support context, check it before sending the request.
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+	if fi.IsDir() {
+		err = fmt.Errorf("%s is a directory: %w", filePath, services.ErrRestrictionDissatisfied)
+		return
+	}
+	if fi.Size() > writeSizeMaximum {
+		err = fmt.Errorf("size limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	sseOptions, err := formatServerSideEncryptionOptions(serverSideEncryptionPairs{
+		HasServerSideEncryption:               opt.HasServerSideEncryption,
+		ServerSideEncryption:                  opt.ServerSideEncryption,
+		HasServerSideDataEncryption:           opt.HasServerSideDataEncryption,
+		ServerSideDataEncryption:              opt.ServerSideDataEncryption,
+		HasServerSideEncryptionKeyID:          opt.HasServerSideEncryptionKeyID,
+		ServerSideEncryptionKeyID:             opt.ServerSideEncryptionKeyID,
+		HasServerSideEncryptionCustomerKey:    opt.HasServerSideEncryptionCustomerKey,
+		ServerSideEncryptionCustomerKey:       opt.ServerSideEncryptionCustomerKey,
+		HasServerSideEncryptionCustomerKeyMd5: opt.HasServerSideEncryptionCustomerKeyMd5,
+		ServerSideEncryptionCustomerKeyMd5:    opt.ServerSideEncryptionCustomerKeyMd5,
+	})
+	if err != nil {
+		return
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	// OSS SDK will set Content-Length from the opened file, and detect the
+	// content type by the file's extension if content_type is not set.
+	options := make([]oss.Option, 0, 3)
+	if opt.HasContentType && opt.ContentType != "" {
+		options = append(options, oss.ContentType(opt.ContentType))
+	}
+	if opt.HasObjectACL {
+		options = append(options, oss.ObjectACL(oss.ACLType(opt.ObjectACL)))
+	}
+	if opt.HasStorageClass {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opt.StorageClass)))
+	}
+	options = append(options, sseOptions...)
+	for k, v := range opt.UserMetadata {
+		options = append(options, oss.Meta(strings.ToLower(k), encodeUserMetadataValue(v)))
+	}
+
+	err = s.bucket.PutObjectFromFile(rp, filePath, options...)
+	if err != nil {
+		return
+	}
+	return fi.Size(), nil
+}
+
+func (s *Storage) writeMultipart(ctx context.Context, o *Object, r io.Reader, size int64, index int, opt pairStorageWriteMultipart) (n int64, part *Part, err error) {
+	if index < 0 || index >= multipartNumberMaximum {
+		err = fmt.Errorf("multipart number limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
+	}
+	if size > multipartSizeMaximum {
+		err = fmt.Errorf("part size %d is larger than maximum %d: %w",
+			size, multipartSizeMaximum, services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	imur := oss.InitiateMultipartUploadResult{
+		Bucket:   s.bucket.BucketName,
+		Key:      o.ID,
 		UploadID: o.MustGetMultipartID(),
 	}
 
@@ -664,6 +2339,16 @@ func (s *Storage) writeMultipart(ctx context.Context, o *Object, r io.Reader, si
 	if opt.HasContentMd5 {
 		options = append(options, oss.ContentMD5(opt.ContentMd5))
 	}
+	if opt.HasProgressCallback {
+		options = append(options, oss.Progress(progressListener(opt.ProgressCallback)))
+	}
+	if opt.HasServerSideEncryptionCustomerKey || opt.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err := formatServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return 0, nil, err
+		}
+		options = append(options, sseOptions...)
+	}
 
 	// For OSS, the `partNumber` is [1, 10000]. But for user, the `partNumber` is zero-based.
 	// Set partNumber=index+1 here to ensure pass in the effective `partNumber` for `UpdatePart`.
@@ -681,3 +2366,157 @@ func (s *Storage) writeMultipart(ctx context.Context, o *Object, r io.Reader, si
 	}
 	return size, part, nil
 }
+
+func (s *Storage) writeMultipartCopy(ctx context.Context, o *Object, src string, offset, size int64, index int, opt pairStorageWriteMultipartCopy) (part *Part, err error) {
+	if index < 0 || index >= multipartNumberMaximum {
+		err = fmt.Errorf("multipart number limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
+	}
+	if size > multipartSizeMaximum {
+		err = fmt.Errorf("part size %d is larger than maximum %d: %w",
+			size, multipartSizeMaximum, services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	imur := oss.InitiateMultipartUploadResult{
+		Bucket:   s.bucket.BucketName,
+		Key:      o.ID,
+		UploadID: o.MustGetMultipartID(),
+	}
+
+	rs, err := s.getAbsPath(src)
+	if err != nil {
+		return
+	}
+
+	var options []oss.Option
+	if opt.HasServerSideEncryptionCustomerKey || opt.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err := formatServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return nil, err
+		}
+		srcSSEOptions, err := formatCopySourceServerSideEncryptionCustomerOptions(opt.ServerSideEncryptionCustomerKey, opt.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return nil, err
+		}
+		options = append(sseOptions, srcSSEOptions...)
+	}
+
+	// Set partNumber=index+1 here to ensure pass in the effective `partNumber` for `UploadPartCopy`.
+	output, err := s.bucket.UploadPartCopy(imur, s.bucket.BucketName, rs, offset, size, index+1, options...)
+	if err != nil {
+		return
+	}
+
+	part = &Part{
+		Index: index,
+		Size:  size,
+		ETag:  output.ETag,
+	}
+	return part, nil
+}
+
+// writeStream writes r with unknown size.
+//
+// Streams smaller than resumablePartSizeDefault will be sent with a single PutObject,
+// so that small streams don't pay for the multipart overhead. Others will be uploaded
+// part by part via multipart upload, and every part will be retried with retry_policy.
+func (s *Storage) writeStream(ctx context.Context, path string, r io.Reader, opt pairStorageWrite) (n int64, err error) {
+	if opt.HasIoCallback {
+		r = iowrap.CallbackReader(r, opt.IoCallback)
+		opt.HasIoCallback = false
+	}
+	// The timeout has been applied to ctx already.
+	opt.HasTimeout = false
+
+	// Read a small probe first, so that small streams don't need to allocate a whole part.
+	buf := make([]byte, writeStreamProbeSize)
+	size, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.write(ctx, path, bytes.NewReader(buf[:size]), int64(size), opt)
+	}
+	if err != nil {
+		return
+	}
+	buf = append(buf, make([]byte, resumablePartSizeDefault-writeStreamProbeSize)...)
+	rest, err := io.ReadFull(r, buf[size:])
+	size += rest
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.write(ctx, path, bytes.NewReader(buf[:size]), int64(size), opt)
+	}
+	if err != nil {
+		return
+	}
+
+	// Multipart upload can't honor the following pairs, reject them instead of ignoring silently.
+	var unsupported []Pair
+	if opt.HasCacheControl {
+		unsupported = append(unsupported, WithCacheControl(opt.CacheControl))
+	}
+	if opt.HasCallback {
+		unsupported = append(unsupported, WithCallback(opt.Callback))
+	}
+	if opt.HasCallbackResult {
+		unsupported = append(unsupported, WithCallbackResult(opt.CallbackResult))
+	}
+	if opt.HasCallbackVar {
+		unsupported = append(unsupported, WithCallbackVar(opt.CallbackVar))
+	}
+	if opt.HasContentDisposition {
+		unsupported = append(unsupported, WithContentDisposition(opt.ContentDisposition))
+	}
+	if opt.HasContentEncoding {
+		unsupported = append(unsupported, WithContentEncoding(opt.ContentEncoding))
+	}
+	if opt.HasContentMd5 {
+		unsupported = append(unsupported, ps.WithContentMd5(opt.ContentMd5))
+	}
+	if opt.HasExpires {
+		unsupported = append(unsupported, WithExpires(opt.Expires))
+	}
+	if opt.HasObjectMetadataCallback {
+		unsupported = append(unsupported, WithObjectMetadataCallback(opt.ObjectMetadataCallback))
+	}
+	if opt.HasObjectTagging {
+		unsupported = append(unsupported, WithObjectTagging(opt.ObjectTagging))
+	}
+	if opt.HasProgressCallback {
+		unsupported = append(unsupported, WithProgressCallback(opt.ProgressCallback))
+	}
+	if opt.HasTrafficLimit {
+		unsupported = append(unsupported, WithTrafficLimit(opt.TrafficLimit))
+	}
+	if opt.HasUserMetadata {
+		unsupported = append(unsupported, WithUserMetadata(opt.UserMetadata))
+	}
+	if len(unsupported) > 0 {
+		return 0, formatPairUnsupportedError(unsupported)
+	}
+
+	// The pairs have been parsed for write already, don't parse them again for
+	// create_multipart, which will reject write only pairs like retry_policy.
+	mopt := pairStorageCreateMultipart{
+		HasContentType:                        opt.HasContentType,
+		ContentType:                           opt.ContentType,
+		HasForbidOverwrite:                    opt.HasForbidOverwrite,
+		ForbidOverwrite:                       opt.ForbidOverwrite,
+		HasObjectACL:                          opt.HasObjectACL,
+		ObjectACL:                             opt.ObjectACL,
+		HasServerSideDataEncryption:           opt.HasServerSideDataEncryption,
+		ServerSideDataEncryption:              opt.ServerSideDataEncryption,
+		HasServerSideEncryption:               opt.HasServerSideEncryption,
+		ServerSideEncryption:                  opt.ServerSideEncryption,
+		HasServerSideEncryptionCustomerKey:    opt.HasServerSideEncryptionCustomerKey,
+		ServerSideEncryptionCustomerKey:       opt.ServerSideEncryptionCustomerKey,
+		HasServerSideEncryptionCustomerKeyMd5: opt.HasServerSideEncryptionCustomerKeyMd5,
+		ServerSideEncryptionCustomerKeyMd5:    opt.ServerSideEncryptionCustomerKeyMd5,
+		HasServerSideEncryptionKeyID:          opt.HasServerSideEncryptionKeyID,
+		ServerSideEncryptionKeyID:             opt.ServerSideEncryptionKeyID,
+		HasStorageClass:                       opt.HasStorageClass,
+		StorageClass:                          opt.StorageClass,
+	}
+
+	// Parts are buffered, so they can be retried with retry_policy.
+	r = io.MultiReader(bytes.NewReader(buf[:size]), r)
+	return s.uploadMultipart(ctx, path, r, resumablePartSizeDefault, 1, opt.RetryPolicy, mopt)
+}
@@ -1,10 +1,18 @@
 package tests
 
 import (
+	"bytes"
+	"io"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/google/uuid"
+
 	tests "github.com/beyondstorage/go-integration-test/v4"
+	oss "github.com/beyondstorage/go-service-oss/v2"
+	ps "github.com/beyondstorage/go-storage/v4/pairs"
+	"github.com/beyondstorage/go-storage/v4/types"
 )
 
 func TestStorage(t *testing.T) {
@@ -41,3 +49,187 @@ func TestLinker(t *testing.T) {
 	}
 	tests.TestLinker(t, setupTest(t))
 }
+
+func TestStorageClass(t *testing.T) {
+	if os.Getenv("STORAGE_OSS_INTEGRATION_TEST") != "on" {
+		t.Skipf("STORAGE_OSS_INTEGRATION_TEST is not 'on', skipped")
+	}
+	store := setupTest(t)
+
+	path := uuid.New().String()
+	content := []byte("storage class")
+	_, err := store.Write(path, bytes.NewReader(content), int64(len(content)),
+		oss.WithStorageClass(oss.StorageClassIA),
+		ps.WithContentType("text/plain"),
+	)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	defer func() {
+		if err := store.Delete(path); err != nil {
+			t.Errorf("delete: %v", err)
+		}
+	}()
+
+	o, err := store.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if sc := oss.GetObjectSystemMetadata(o).StorageClass; sc != oss.StorageClassIA {
+		t.Errorf("stat storage class: expected %s, got %s", oss.StorageClassIA, sc)
+	}
+	if ct, _ := o.GetContentType(); ct != "text/plain" {
+		t.Errorf("stat content type: expected %s, got %s", "text/plain", ct)
+	}
+
+	it, err := store.List("")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for {
+		o, err := it.Next()
+		if err == types.IterateDone {
+			t.Fatalf("list: object %s not found", path)
+		}
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if o.Path != path {
+			continue
+		}
+		if sc := oss.GetObjectSystemMetadata(o).StorageClass; sc != oss.StorageClassIA {
+			t.Errorf("list storage class: expected %s, got %s", oss.StorageClassIA, sc)
+		}
+		break
+	}
+}
+
+func TestStatDirMarker(t *testing.T) {
+	if os.Getenv("STORAGE_OSS_INTEGRATION_TEST") != "on" {
+		t.Skipf("STORAGE_OSS_INTEGRATION_TEST is not 'on', skipped")
+	}
+	store := setupTest(t)
+
+	// Create the directory marker manually instead of CreateDir.
+	path := uuid.New().String()
+	_, err := store.Write(path+"/", nil, 0)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	defer func() {
+		if err := store.Delete(path + "/"); err != nil {
+			t.Errorf("delete: %v", err)
+		}
+	}()
+
+	for _, p := range []string{path + "/", path} {
+		o, err := store.Stat(p)
+		if err != nil {
+			t.Fatalf("stat %s: %v", p, err)
+		}
+		if !o.Mode.IsDir() {
+			t.Errorf("stat %s: expected dir, got %s", p, o.Mode)
+		}
+		if !strings.HasSuffix(o.ID, path+"/") {
+			t.Errorf("stat %s: expected id ends with %s, got %s", p, path+"/", o.ID)
+		}
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	if os.Getenv("STORAGE_OSS_INTEGRATION_TEST") != "on" {
+		t.Skipf("STORAGE_OSS_INTEGRATION_TEST is not 'on', skipped")
+	}
+	store := setupTest(t)
+
+	for _, r := range []io.Reader{nil, bytes.NewReader(nil)} {
+		path := uuid.New().String()
+		n, err := store.Write(path, r, 0)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if n != 0 {
+			t.Errorf("write size: expected %d, got %d", 0, n)
+		}
+
+		o, err := store.Stat(path)
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if size, _ := o.GetContentLength(); size != 0 {
+			t.Errorf("stat size: expected %d, got %d", 0, size)
+		}
+
+		if err := store.Delete(path); err != nil {
+			t.Errorf("delete: %v", err)
+		}
+	}
+}
+
+func TestWriteStream(t *testing.T) {
+	if os.Getenv("STORAGE_OSS_INTEGRATION_TEST") != "on" {
+		t.Skipf("STORAGE_OSS_INTEGRATION_TEST is not 'on', skipped")
+	}
+	store := setupTest(t)
+
+	// The second size is larger than a part, so that multipart upload will be used.
+	for _, size := range []int64{1024, 9 * 1024 * 1024} {
+		path := uuid.New().String()
+		content := bytes.Repeat([]byte("a"), int(size))
+
+		// Hide the reader type so that the size can't be detected.
+		r := struct{ io.Reader }{bytes.NewReader(content)}
+		n, err := store.Write(path, r, -1)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if n != size {
+			t.Errorf("write size: expected %d, got %d", size, n)
+		}
+
+		o, err := store.Stat(path)
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if v, _ := o.GetContentLength(); v != size {
+			t.Errorf("stat size: expected %d, got %d", size, v)
+		}
+
+		if err := store.Delete(path); err != nil {
+			t.Errorf("delete: %v", err)
+		}
+	}
+}
+
+func TestCopyStorageClass(t *testing.T) {
+	if os.Getenv("STORAGE_OSS_INTEGRATION_TEST") != "on" {
+		t.Skipf("STORAGE_OSS_INTEGRATION_TEST is not 'on', skipped")
+	}
+	store := setupTest(t)
+
+	path := uuid.New().String()
+	content := []byte("storage class")
+	_, err := store.Write(path, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	defer func() {
+		if err := store.Delete(path); err != nil {
+			t.Errorf("delete: %v", err)
+		}
+	}()
+
+	// Copy the object onto itself to change the storage class in place.
+	err = store.(types.Copier).Copy(path, path, oss.WithStorageClass(oss.StorageClassIA))
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+
+	o, err := store.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if sc := oss.GetObjectSystemMetadata(o).StorageClass; sc != oss.StorageClassIA {
+		t.Errorf("stat storage class: expected %s, got %s", oss.StorageClassIA, sc)
+	}
+}
@@ -1,8 +1,30 @@
 package oss
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 
@@ -36,14 +58,26 @@ type Storage struct {
 	name    string
 	workDir string
 
+	// properties is the bucket properties returned by ListBuckets, only set for
+	// the storagers listed by Service.List.
+	properties *oss.BucketProperties
+
 	defaultPairs DefaultStoragePairs
 	features     StorageFeatures
 
+	// bucketChecked will be set to 1 once the bucket is known to exist or the
+	// check is refused, so that it will not be checked again for every object
+	// not found.
+	bucketChecked int32
+
 	typ.UnimplementedStorager
 	typ.UnimplementedAppender
+	typ.UnimplementedCopier
 	typ.UnimplementedMultiparter
+	typ.UnimplementedMover
 	typ.UnimplementedDirer
 	typ.UnimplementedLinker
+	typ.UnimplementedStorageHTTPSigner
 }
 
 // String implements Storager.String
@@ -54,6 +88,9 @@ func (s *Storage) String() string {
 	)
 }
 
+// TLSConfig is an alias of tls.Config, so that it can be used in the tls_config pair.
+type TLSConfig = tls.Config
+
 // New will create both Servicer and Storager.
 func New(pairs ...typ.Pair) (typ.Servicer, typ.Storager, error) {
 	return newServicerAndStorager(pairs...)
@@ -84,39 +121,120 @@ func newServicer(pairs ...typ.Pair) (srv *Service, err error) {
 		return nil, err
 	}
 
-	cp, err := credential.Parse(opt.Credential)
-	if err != nil {
-		return nil, err
-	}
-	if cp.Protocol() != credential.ProtocolHmac {
-		return nil, services.PairUnsupportedError{Pair: ps.WithCredential(opt.Credential)}
+	var copts []oss.ClientOption
+
+	// Requests will not be signed in anonymous mode, so no credential is needed.
+	var ak, sk string
+	anonymous := opt.HasAnonymous && opt.Anonymous
+	switch {
+	case anonymous:
+		if opt.HasCredential {
+			return nil, services.PairUnsupportedError{Pair: ps.WithCredential(opt.Credential)}
+		}
+	case !opt.HasCredential:
+		return nil, services.PairRequiredError{Keys: []string{"credential"}}
+	case strings.SplitN(opt.Credential, ":", 2)[0] == credentialProtocolECSRAMRole:
+		// credential.Parse doesn't support ecs_ram_role, which is specific to OSS.
+		if opt.HasSecurityToken {
+			return nil, services.PairUnsupportedError{Pair: WithSecurityToken(opt.SecurityToken)}
+		}
+		roleName := strings.TrimPrefix(strings.TrimPrefix(opt.Credential, credentialProtocolECSRAMRole), ":")
+		provider, err := newECSRAMRoleProvider(roleName)
+		if err != nil {
+			return nil, err
+		}
+		copts = append(copts, oss.SetCredentialsProvider(provider))
+	default:
+		cp, err := credential.Parse(opt.Credential)
+		if err != nil {
+			return nil, err
+		}
+		if cp.Protocol() != credential.ProtocolHmac {
+			return nil, services.PairUnsupportedError{Pair: ps.WithCredential(opt.Credential)}
+		}
+		ak, sk = cp.Hmac()
 	}
-	ak, sk := cp.Hmac()
 
-	ep, err := endpoint.Parse(opt.Endpoint)
+	url, protocol, err := parseEndpoint(opt.Endpoint)
 	if err != nil {
 		return nil, err
 	}
-
-	var url string
-	switch ep.Protocol() {
-	case endpoint.ProtocolHTTP:
-		url, _, _ = ep.HTTP()
-	case endpoint.ProtocolHTTPS:
-		url, _, _ = ep.HTTPS()
-	default:
+	if opt.HasForceHTTPS && opt.ForceHTTPS && protocol != endpoint.ProtocolHTTPS {
 		return nil, services.PairUnsupportedError{Pair: ps.WithEndpoint(opt.Endpoint)}
 	}
+	if opt.HasUseDualStackEndpoint && opt.UseDualStackEndpoint {
+		url, err = formatDualStackEndpoint(url)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.HasUseInternalEndpoint && opt.UseInternalEndpoint {
+		url, err = formatInternalEndpoint(url)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	var copts []oss.ClientOption
-	if opt.HasHTTPClientOptions {
-		copts = append(copts, oss.HTTPClient(httpclient.New(opt.HTTPClientOptions)))
+	// Our HTTP client respects proxies from environment variables, but OSS SDK's
+	// default client doesn't.
+	if opt.HasHTTPClientOptions || opt.HasTLSConfig || opt.HasProxyURL || anonymous {
+		// Copy the options so that the caller's options will not be modified.
+		var hco httpclient.Options
+		if opt.HTTPClientOptions != nil {
+			hco = *opt.HTTPClientOptions
+		}
+		// Timeouts in http_client_options take precedence.
+		if opt.HasConnectTimeout && hco.DialerConnectTimeout == 0 {
+			hco.DialerConnectTimeout = opt.ConnectTimeout
+		}
+		if opt.HasReadWriteTimeout && hco.ConnReadTimeout == 0 {
+			hco.ConnReadTimeout = opt.ReadWriteTimeout
+		}
+		if opt.HasReadWriteTimeout && hco.ConnWriteTimeout == 0 {
+			hco.ConnWriteTimeout = opt.ReadWriteTimeout
+		}
+
+		hc := httpclient.New(&hco)
+		tr := hc.Transport.(*http.Transport)
+		if opt.HasTLSConfig {
+			tr.TLSClientConfig = opt.TLSConfig
+		}
+		if opt.HasProxyURL {
+			proxy, err := neturl.Parse(opt.ProxyURL)
+			if err != nil || proxy.Host == "" {
+				return nil, services.PairUnsupportedError{Pair: WithProxyURL(opt.ProxyURL)}
+			}
+			tr.Proxy = http.ProxyURL(proxy)
+		}
+		if anonymous {
+			hc.Transport = anonymousTransport{rt: tr}
+		}
+		copts = append(copts, oss.HTTPClient(hc))
+	} else if opt.HasConnectTimeout || opt.HasReadWriteTimeout {
+		connectTimeout, readWriteTimeout := connectTimeoutDefault, readWriteTimeoutDefault
+		if opt.HasConnectTimeout {
+			connectTimeout = opt.ConnectTimeout
+		}
+		if opt.HasReadWriteTimeout {
+			readWriteTimeout = opt.ReadWriteTimeout
+		}
+		copts = append(copts, oss.Timeout(formatTimeoutSeconds(connectTimeout), formatTimeoutSeconds(readWriteTimeout)))
+	}
+	if opt.HasSecurityToken {
+		copts = append(copts, oss.SecurityToken(opt.SecurityToken))
+	}
+	if opt.HasEnableCname && opt.EnableCname {
+		copts = append(copts, oss.UseCname(true))
 	}
 
 	srv.service, err = oss.New(url, ak, sk, copts...)
 	if err != nil {
 		return nil, err
 	}
+	// OSS SDK doesn't expose the default User-Agent, append to it after the client is created.
+	if opt.HasAppendUserAgent && opt.AppendUserAgent != "" {
+		srv.service.Config.UserAgent += " " + opt.AppendUserAgent
+	}
 
 	if opt.HasDefaultServicePairs {
 		srv.defaultPairs = opt.DefaultServicePairs
@@ -146,43 +264,371 @@ const (
 	storageClassHeader = "x-oss-storage-class"
 
 	// ref: https://www.alibabacloud.com/help/doc-detail/51374.htm
-	StorageClassStandard = "STANDARD"
-	StorageClassIA       = "IA"
-	StorageClassArchive  = "Archive"
+	StorageClassStandard        = "STANDARD"
+	StorageClassIA              = "IA"
+	StorageClassArchive         = "Archive"
+	StorageClassColdArchive     = "ColdArchive"
+	StorageClassDeepColdArchive = "DeepColdArchive"
+)
+
+// All available metadata directives are listed here.
+//
+// ref: https://www.alibabacloud.com/help/doc-detail/31979.htm
+const (
+	MetadataDirectiveCopy    = "COPY"
+	MetadataDirectiveReplace = "REPLACE"
+)
+
+// All available restore tiers are listed here.
+//
+// ref: https://www.alibabacloud.com/help/doc-detail/52930.htm
+const (
+	RestoreTierExpedited = "Expedited"
+	RestoreTierStandard  = "Standard"
+	RestoreTierBulk      = "Bulk"
 )
 
+var (
+	// ErrRestoreAlreadyInProgress will be returned while restoring an object which is being restored.
+	ErrRestoreAlreadyInProgress = services.NewErrorCode("restore already in progress")
+	// ErrObjectNotRestored will be returned while reading an archived object which has not been restored.
+	ErrObjectNotRestored = services.NewErrorCode("object not restored")
+	// ErrCrc64Mismatch will be returned while the CRC64 computed by OSS SDK doesn't match the one returned by OSS.
+	ErrCrc64Mismatch = services.NewErrorCode("crc64 mismatch")
+	// ErrAppendPositionMismatch will be returned while the append position doesn't match the object's length.
+	ErrAppendPositionMismatch = services.NewErrorCode("append position mismatch")
+	// ErrBucketNotEmpty will be returned while deleting a bucket which is not empty.
+	ErrBucketNotEmpty = services.NewErrorCode("bucket not empty")
+	// ErrContentMd5Mismatch will be returned while the content_md5 is invalid or doesn't match the content.
+	ErrContentMd5Mismatch = services.NewErrorCode("content md5 mismatch")
+	// ErrObjectNotModified will be returned while the object doesn't satisfy if_none_match or if_modified_since.
+	ErrObjectNotModified = services.NewErrorCode("object not modified")
+	// ErrPreconditionFailed will be returned while the object doesn't satisfy if_match or if_unmodified_since.
+	ErrPreconditionFailed = services.NewErrorCode("precondition failed")
+	// ErrBucketNotExist will be returned while the bucket does not exist.
+	ErrBucketNotExist = services.NewErrorCode("bucket not exist")
+	// ErrBucketAlreadyExists will be returned while creating a bucket which has been taken.
+	ErrBucketAlreadyExists = services.NewErrorCode("bucket already exists")
+	// ErrRequestTimeTooSkewed will be returned while the local clock is out of sync, please sync the clock.
+	ErrRequestTimeTooSkewed = services.NewErrorCode("request time too skewed")
+	// ErrEndpointMismatch will be returned while the bucket is not in the region of the endpoint,
+	// the correct endpoint will be included in the error message.
+	ErrEndpointMismatch = services.NewErrorCode("endpoint mismatch")
+	// ErrSignatureMismatch will be returned while the request signature computed by OSS doesn't match ours,
+	// which is caused by a wrong secret key mostly. It's different from services.ErrPermissionDenied,
+	// which means the credential is valid but not allowed to do the operation.
+	ErrSignatureMismatch = services.NewErrorCode("signature mismatch")
+	// ErrObjectAlreadyExists will be returned while writing an existing object with forbid_overwrite.
+	ErrObjectAlreadyExists = services.NewErrorCode("object already exists")
+	// ErrSizeMismatch will be returned while the reader ends before the size passed to Write.
+	ErrSizeMismatch = services.NewErrorCode("size mismatch")
+)
+
+// ResponseError carries the request ID and host ID returned by OSS, which are
+// required while asking Alibaba Cloud support for help.
+//
+// The formatted error is wrapped, so errors.Is still works as before, and
+// ResponseError can be retrieved via errors.As.
+type ResponseError struct {
+	// Code is the error code returned by OSS, like NoSuchKey.
+	Code string
+	// RequestID is the x-oss-request-id of the failed request.
+	RequestID string
+	// HostID is the OSS cluster which handled the failed request.
+	HostID string
+
+	Err error
+}
+
+// Error implements error, the request ID has been included in the wrapped error already.
+func (e ResponseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap implements xerrors.Wrapper
+func (e ResponseError) Unwrap() error {
+	return e.Err
+}
+
 func formatError(err error) error {
-	if _, ok := err.(services.InternalError); ok {
+	// Errors wrapping our internal errors have been formatted already.
+	var ie services.InternalError
+	if errors.As(err, &ie) {
+		return err
+	}
+	// Keep context errors as is, so that callers can check them via errors.Is.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return err
 	}
 
 	switch e := err.(type) {
 	case oss.ServiceError:
-		switch e.Code {
-		case "":
-			switch e.StatusCode {
-			case 404:
-				return fmt.Errorf("%w: %v", services.ErrObjectNotExist, err)
-			default:
-				return fmt.Errorf("%w, %v", services.ErrUnexpected, err)
-			}
-		case "NoSuchKey":
-			return fmt.Errorf("%w: %v", services.ErrObjectNotExist, err)
-		case "AccessDenied":
-			return fmt.Errorf("%w: %v", services.ErrPermissionDenied, err)
+		return ResponseError{
+			Code:      e.Code,
+			RequestID: e.RequestID,
+			HostID:    e.HostID,
+			Err:       formatServiceError(e),
 		}
+	case oss.CRCCheckError:
+		return fmt.Errorf("%w: %v", ErrCrc64Mismatch, err)
 	case oss.UnexpectedStatusCodeError:
 		switch e.Got() {
 		case 404:
 			return fmt.Errorf("%w: %v", services.ErrObjectNotExist, err)
 		case 403:
 			return fmt.Errorf("%w: %v", services.ErrPermissionDenied, err)
+		case 503:
+			return fmt.Errorf("%w: %v", services.ErrRequestThrottled, err)
 		}
 	}
 
 	return fmt.Errorf("%w, %v", services.ErrUnexpected, err)
 }
 
+// formatPairUnsupportedError will report all unsupported pairs in one error,
+// which can still be checked via errors.As with services.PairUnsupportedError.
+func formatPairUnsupportedError(pairs []typ.Pair) error {
+	err := error(services.PairUnsupportedError{Pair: pairs[0]})
+	if len(pairs) == 1 {
+		return err
+	}
+	keys := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		keys = append(keys, p.Key)
+	}
+	return fmt.Errorf("%w, all unsupported pairs: %s", err, strings.Join(keys, ", "))
+}
+
+// formatServiceError will map the error code returned by OSS to our errors.
+func formatServiceError(e oss.ServiceError) error {
+	// OSS will return the correct endpoint if the bucket is not in the region of our endpoint.
+	//
+	// HEAD requests don't have a response body, so the endpoint is only available for other requests.
+	if e.Endpoint != "" {
+		return fmt.Errorf("%w: bucket must be accessed via endpoint %s: %v", ErrEndpointMismatch, e.Endpoint, e)
+	}
+
+	switch e.Code {
+	case "":
+		switch e.StatusCode {
+		case 404:
+			return fmt.Errorf("%w: %v", services.ErrObjectNotExist, e)
+		case 304:
+			return fmt.Errorf("%w: %v", ErrObjectNotModified, e)
+		case 503:
+			return fmt.Errorf("%w: %v", services.ErrRequestThrottled, e)
+		default:
+			return fmt.Errorf("%w, %v", services.ErrUnexpected, e)
+		}
+	case responseCodeNoSuchKey, responseCodeNoSuchUpload:
+		return fmt.Errorf("%w: %v", services.ErrObjectNotExist, e)
+	case "AccessDenied":
+		return fmt.Errorf("%w: %v", services.ErrPermissionDenied, e)
+	case responseCodeRestoreAlreadyInProgress:
+		return fmt.Errorf("%w: %v", ErrRestoreAlreadyInProgress, e)
+	case responseCodeInvalidObjectState:
+		return fmt.Errorf("%w: %v", ErrObjectNotRestored, e)
+	case responseCodePositionNotEqualToLength:
+		return fmt.Errorf("%w: %v", ErrAppendPositionMismatch, e)
+	case responseCodeBucketNotEmpty:
+		return fmt.Errorf("%w: %v", ErrBucketNotEmpty, e)
+	case responseCodeBadDigest, responseCodeInvalidDigest:
+		return fmt.Errorf("%w: %v", ErrContentMd5Mismatch, e)
+	case responseCodePreconditionFailed:
+		return fmt.Errorf("%w: %v", ErrPreconditionFailed, e)
+	case responseCodeNoSuchBucket:
+		return fmt.Errorf("%w: %v", ErrBucketNotExist, e)
+	case responseCodeBucketAlreadyExists:
+		return fmt.Errorf("%w: %v", ErrBucketAlreadyExists, e)
+	case responseCodeSignatureDoesNotMatch:
+		return fmt.Errorf("%w: %s, please check the secret key and the local clock: %v", ErrSignatureMismatch, e.Code, e)
+	case responseCodeInvalidAccessKeyID:
+		return fmt.Errorf("%w: %v", services.ErrPermissionDenied, e)
+	case responseCodeRequestTimeTooSkewed:
+		return fmt.Errorf("%w: %v", ErrRequestTimeTooSkewed, e)
+	case responseCodeInvalidBucketName:
+		return fmt.Errorf("%w: %v", services.ErrRestrictionDissatisfied, e)
+	case responseCodeInternalError:
+		return fmt.Errorf("%w: %v", services.ErrServiceInternal, e)
+	case responseCodeSlowDown, responseCodeServiceUnavailable:
+		return fmt.Errorf("%w: %v", services.ErrRequestThrottled, e)
+	case responseCodeFileAlreadyExists:
+		return fmt.Errorf("%w: %v", ErrObjectAlreadyExists, e)
+	}
+
+	return fmt.Errorf("%w, %v", services.ErrUnexpected, e)
+}
+
+// Default timeouts of OSS SDK's client, used while only one of them is set.
+const (
+	connectTimeoutDefault   = 30 * time.Second
+	readWriteTimeoutDefault = 60 * time.Second
+)
+
+// formatTimeoutSeconds will convert the timeout into seconds for oss.Timeout.
+//
+// Timeouts less than a second will be rounded up, because 0 means no timeout in OSS SDK.
+func formatTimeoutSeconds(d time.Duration) int64 {
+	sec := int64((d + time.Second - 1) / time.Second)
+	if sec < 1 {
+		sec = 1
+	}
+	return sec
+}
+
+// parseEndpoint will parse the endpoint into the URL used by OSS SDK.
+//
+// endpoint.Parse splits the endpoint by `:`, so IPv6 literals like
+// `http:[::1]:9000` are parsed here instead. OSS SDK uses path style for IP
+// endpoints, including IPv6.
+func parseEndpoint(cfg string) (url string, protocol string, err error) {
+	idx := strings.Index(cfg, ":[")
+	if idx < 0 {
+		ep, err := endpoint.Parse(cfg)
+		if err != nil {
+			return "", "", err
+		}
+
+		switch ep.Protocol() {
+		case endpoint.ProtocolHTTP:
+			url, _, _ = ep.HTTP()
+		case endpoint.ProtocolHTTPS:
+			url, _, _ = ep.HTTPS()
+		default:
+			return "", "", services.PairUnsupportedError{Pair: ps.WithEndpoint(cfg)}
+		}
+		return url, ep.Protocol(), nil
+	}
+
+	protocol = cfg[:idx]
+	if protocol != endpoint.ProtocolHTTP && protocol != endpoint.ProtocolHTTPS {
+		return "", "", services.PairUnsupportedError{Pair: ps.WithEndpoint(cfg)}
+	}
+
+	hostPort := cfg[idx+1:]
+	host, port := strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]"), ""
+	if !strings.HasSuffix(hostPort, "]") {
+		host, port, err = net.SplitHostPort(hostPort)
+		if err != nil {
+			return "", "", services.PairUnsupportedError{Pair: ps.WithEndpoint(cfg)}
+		}
+		if _, err = strconv.ParseUint(port, 10, 16); err != nil {
+			return "", "", services.PairUnsupportedError{Pair: ps.WithEndpoint(cfg)}
+		}
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.To4() != nil {
+		return "", "", services.PairUnsupportedError{Pair: ps.WithEndpoint(cfg)}
+	}
+
+	// Omit the default port like endpoint.Parse does.
+	if (protocol == endpoint.ProtocolHTTP && port == "80") || (protocol == endpoint.ProtocolHTTPS && port == "443") {
+		port = ""
+	}
+	if port == "" {
+		return protocol + "://[" + host + "]", protocol, nil
+	}
+	return protocol + "://" + net.JoinHostPort(host, port), protocol, nil
+}
+
+// parseEndpointRegion will parse the region from the host of OSS endpoint.
+//
+// Both of the IPv4 endpoints like oss-cn-hangzhou.aliyuncs.com and the
+// dual-stack endpoints like cn-hangzhou.oss.aliyuncs.com are supported.
+//
+// ref: https://help.aliyun.com/document_detail/31837.html
+func parseEndpointRegion(host string) (region string, internal, dualStack, ok bool) {
+	switch {
+	case strings.HasSuffix(host, ".oss.aliyuncs.com"):
+		region, dualStack = strings.TrimSuffix(host, ".oss.aliyuncs.com"), true
+	case strings.HasPrefix(host, "oss-") && strings.HasSuffix(host, ".aliyuncs.com"):
+		region = strings.TrimSuffix(strings.TrimPrefix(host, "oss-"), ".aliyuncs.com")
+	default:
+		return "", false, false, false
+	}
+	if region == "" || strings.Contains(region, ".") {
+		return "", false, false, false
+	}
+	if strings.HasSuffix(region, "-internal") {
+		region, internal = strings.TrimSuffix(region, "-internal"), true
+	}
+	return region, internal, dualStack, true
+}
+
+// checkEndpointLocation will check whether location like oss-cn-hangzhou is the
+// region of endpoint. Custom endpoints like CNAME and IP addresses are always
+// allowed since their regions are unknown.
+func checkEndpointLocation(endpoint, location string) bool {
+	u, err := neturl.Parse(endpoint)
+	if err != nil {
+		return true
+	}
+	region, _, _, ok := parseEndpointRegion(u.Hostname())
+	if !ok {
+		return true
+	}
+	return region == strings.TrimPrefix(location, "oss-")
+}
+
+// formatEndpointHost is the reverse of parseEndpointRegion.
+func formatEndpointHost(region string, internal, dualStack bool) string {
+	if internal {
+		region += "-internal"
+	}
+	if dualStack {
+		return region + ".oss.aliyuncs.com"
+	}
+	return "oss-" + region + ".aliyuncs.com"
+}
+
+// formatInternalEndpoint will convert a public endpoint into an internal endpoint.
+//
+// For example, https://oss-cn-hangzhou.aliyuncs.com will be converted into
+// https://oss-cn-hangzhou-internal.aliyuncs.com, and the dual-stack endpoint
+// https://cn-hangzhou.oss.aliyuncs.com will be converted into
+// https://cn-hangzhou-internal.oss.aliyuncs.com.
+func formatInternalEndpoint(endpoint string) (string, error) {
+	u, err := neturl.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	host := u.Hostname()
+	region, internal, dualStack, ok := parseEndpointRegion(host)
+	if !ok {
+		return "", fmt.Errorf("endpoint %s is not an OSS endpoint like oss-<region>.aliyuncs.com", endpoint)
+	}
+	if internal {
+		return endpoint, nil
+	}
+
+	u.Host = strings.Replace(u.Host, host, formatEndpointHost(region, true, dualStack), 1)
+	return u.String(), nil
+}
+
+// formatDualStackEndpoint will convert an endpoint into the dual-stack endpoint,
+// which can be accessed via both IPv4 and IPv6.
+//
+// For example, https://oss-cn-hangzhou.aliyuncs.com will be converted into
+// https://cn-hangzhou.oss.aliyuncs.com.
+func formatDualStackEndpoint(endpoint string) (string, error) {
+	u, err := neturl.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	host := u.Hostname()
+	region, internal, dualStack, ok := parseEndpointRegion(host)
+	if !ok {
+		return "", fmt.Errorf("endpoint %s is not an OSS endpoint like oss-<region>.aliyuncs.com", endpoint)
+	}
+	if dualStack {
+		return endpoint, nil
+	}
+
+	u.Host = strings.Replace(u.Host, host, formatEndpointHost(region, internal, true), 1)
+	return u.String(), nil
+}
+
 // newStorage will create a new client.
 func (s *Service) newStorage(pairs ...typ.Pair) (st *Storage, err error) {
 	opt, err := parsePairStorageNew(pairs)
@@ -190,6 +636,11 @@ func (s *Service) newStorage(pairs ...typ.Pair) (st *Storage, err error) {
 		return nil, err
 	}
 
+	err = checkBucketName(opt.Name)
+	if err != nil {
+		return nil, err
+	}
+
 	bucket, err := s.service.Bucket(opt.Name)
 	if err != nil {
 		return nil, err
@@ -204,11 +655,18 @@ func (s *Service) newStorage(pairs ...typ.Pair) (st *Storage, err error) {
 	if opt.HasDefaultStoragePairs {
 		store.defaultPairs = opt.DefaultStoragePairs
 	}
+	// Storagers created by Service.Create, Service.Get and Service.List inherit
+	// the service features, storage_features takes precedence if set.
+	store.features.VirtualDir = s.features.VirtualDir
 	if opt.HasStorageFeatures {
 		store.features = opt.StorageFeatures
 	}
 	if opt.HasWorkDir {
-		store.workDir = opt.WorkDir
+		workDir, err := formatWorkDir(opt.WorkDir)
+		if err != nil {
+			return nil, services.PairUnsupportedError{Pair: ps.WithWorkDir(opt.WorkDir)}
+		}
+		store.workDir = workDir
 	}
 	return store, nil
 }
@@ -226,10 +684,63 @@ func (s *Service) formatError(op string, err error, name string) error {
 	}
 }
 
+// checkBucketName will check the bucket name against the naming rules of OSS,
+// so that typos can be reported before sending any request.
+//
+// ref: https://help.aliyun.com/document_detail/31885.html
+func checkBucketName(name string) error {
+	if len(name) < bucketNameLengthMinimum || len(name) > bucketNameLengthMaximum {
+		return fmt.Errorf("bucket name %q must be %d to %d characters long: %w",
+			name, bucketNameLengthMinimum, bucketNameLengthMaximum, services.ErrRestrictionDissatisfied)
+	}
+	for _, v := range name {
+		if !('a' <= v && v <= 'z' || '0' <= v && v <= '9' || v == '-') {
+			return fmt.Errorf("bucket name %q contains %q, only lowercase letters, numbers and hyphens are allowed: %w",
+				name, v, services.ErrRestrictionDissatisfied)
+		}
+	}
+	if name[0] == '-' || name[len(name)-1] == '-' {
+		return fmt.Errorf("bucket name %q must start and end with a lowercase letter or number: %w",
+			name, services.ErrRestrictionDissatisfied)
+	}
+	return nil
+}
+
+// formatWorkDir will normalize the work dir to start and end with `/`, so that
+// it can be used as the prefix of object keys directly.
+//
+// Work dir contains `..` will be rejected.
+func formatWorkDir(workDir string) (string, error) {
+	for _, v := range strings.Split(workDir, "/") {
+		if v == ".." {
+			return "", fmt.Errorf("work dir %s contains ..", workDir)
+		}
+	}
+
+	workDir = path.Clean("/" + workDir)
+	if workDir != "/" {
+		workDir += "/"
+	}
+	return workDir, nil
+}
+
 // getAbsPath will calculate object storage's abs path
-func (s *Storage) getAbsPath(path string) string {
+//
+// The path is relative to work dir even if it starts with `/`, and the error
+// will be returned if it escapes the work dir via `..`. Redundant slashes are
+// collapsed, so keys like `a//b` can't be accessed via this storager, while
+// `.` and `..` inside the path are kept as is since they are valid in OSS.
+func (s *Storage) getAbsPath(p string) (string, error) {
+	rp := strings.TrimLeft(p, "/")
+	for strings.Contains(rp, "//") {
+		rp = strings.ReplaceAll(rp, "//", "/")
+	}
+	if cleaned := path.Clean(rp); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("path %q is outside of work dir: %w", p, services.ErrRestrictionDissatisfied)
+	}
+
 	prefix := strings.TrimPrefix(s.workDir, "/")
-	return prefix + path
+	return prefix + rp, nil
 }
 
 // getRelPath will get object storage's rel path.
@@ -238,6 +749,28 @@ func (s *Storage) getRelPath(path string) string {
 	return strings.TrimPrefix(path, prefix)
 }
 
+// checkBucketExist will return the NoSuchBucket error if the bucket doesn't exist.
+//
+// newStorage doesn't send any request, so a wrong bucket name can only be
+// detected while objects are reported as not exist. Other errors of the check
+// are ignored, because the credential may not be allowed to get bucket info.
+func (s *Storage) checkBucketExist() error {
+	if atomic.LoadInt32(&s.bucketChecked) == 1 {
+		return nil
+	}
+
+	_, err := s.bucket.Client.GetBucketInfo(s.bucket.BucketName)
+	if checkError(err, responseCodeNoSuchBucket) {
+		return err
+	}
+	// Errors returned by OSS like AccessDenied will not go away, don't check
+	// again. Other errors like timeout are transient, the next check may succeed.
+	if _, ok := err.(oss.ServiceError); err == nil || ok {
+		atomic.StoreInt32(&s.bucketChecked, 1)
+	}
+	return nil
+}
+
 func (s *Storage) formatError(op string, err error, path ...string) error {
 	if err == nil {
 		return nil
@@ -257,22 +790,28 @@ func (s *Storage) formatFileObject(v oss.ObjectProperties) (o *typ.Object, err e
 	o.Path = s.getRelPath(v.Key)
 	if v.Type == "Symlink" {
 		o.Mode |= typ.ModeLink
+	} else if strings.HasSuffix(v.Key, "/") {
+		// Objects end with `/` are directory markers created by CreateDir.
+		o.Mode |= typ.ModeDir
 	} else {
 		o.Mode |= typ.ModeRead
 	}
 
 	o.SetContentLength(v.Size)
-	o.SetLastModified(v.LastModified)
+	o.SetLastModified(v.LastModified.UTC())
 
 	// OSS advise us don't use Etag as Content-MD5.
 	//
 	// ref: https://help.aliyun.com/document_detail/31965.html
 	if v.ETag != "" {
-		o.SetEtag(v.ETag)
+		o.SetEtag(strings.Trim(v.ETag, "\""))
 	}
 
+	// ListObjects doesn't return content type and user metadata, `v.Type` is the
+	// object type (Normal, Appendable, Multipart or Symlink) which should not be
+	// used as storage class or content type. User metadata could be got via Stat.
 	var sm ObjectSystemMetadata
-	if value := v.Type; value != "" {
+	if value := v.StorageClass; value != "" {
 		sm.StorageClass = value
 	}
 	o.SetSystemMetadata(sm)
@@ -288,6 +827,12 @@ func (s *Storage) newObject(done bool) *typ.Object {
 const (
 	serverSideEncryptionHeader      = "x-oss-server-side-encryption"
 	serverSideEncryptionKeyIdHeader = "x-oss-server-side-encryption-key-id"
+	serverSideDataEncryptionHeader  = "x-oss-server-side-data-encryption"
+
+	// ref: https://help.aliyun.com/document_detail/31871.html
+	copySourceSSECAlgorithmHeader = "x-oss-copy-source-server-side-encryption-customer-algorithm"
+	copySourceSSECKeyHeader       = "x-oss-copy-source-server-side-encryption-customer-key"
+	copySourceSSECKeyMd5Header    = "x-oss-copy-source-server-side-encryption-customer-key-md5"
 
 	ServerSideEncryptionAES256 = "AES256"
 	ServerSideEncryptionKMS    = "KMS"
@@ -296,14 +841,741 @@ const (
 	ServerSideDataEncryptionSM4 = "SM4"
 )
 
+// All available object ACLs are listed here.
+//
+// ref: https://help.aliyun.com/document_detail/31986.html
+const (
+	ObjectACLDefault         = "default"
+	ObjectACLPrivate         = "private"
+	ObjectACLPublicRead      = "public-read"
+	ObjectACLPublicReadWrite = "public-read-write"
+)
+
+const (
+	// objectTaggingCountHeader is the count of tags returned by HEAD object.
+	//
+	// ref: https://help.aliyun.com/document_detail/106678.html
+	objectTaggingCountHeader = "x-oss-tagging-count"
+)
+
+// All available select json types are listed here.
+//
+// ref: https://help.aliyun.com/document_detail/74054.html
+const (
+	SelectJSONTypeDocument = "DOCUMENT"
+	SelectJSONTypeLines    = "LINES"
+)
+
+// versionIDHeader is the version ID of the object returned by OSS when versioning is enabled.
+//
+// ref: https://help.aliyun.com/document_detail/109695.html
+const versionIDHeader = "x-oss-version-id"
+
+// userMetadataPrefix is the prefix of user metadata headers.
+//
+// ref: https://help.aliyun.com/document_detail/31859.html
+const userMetadataPrefix = "x-oss-meta-"
+
+// userMetadataEncodedXXX are the prefix and suffix of the encoded-word produced
+// by encodeUserMetadataValue.
+const (
+	userMetadataEncodedPrefix = "=?utf-8?b?"
+	userMetadataEncodedSuffix = "?="
+)
+
+// encodeUserMetadataValue will encode user metadata value.
+//
+// OSS only allows ASCII characters in the value of user metadata, so values that
+// contain non-ASCII characters will be encoded as RFC 2047 encoded-word.
+func encodeUserMetadataValue(v string) string {
+	for i := 0; i < len(v); i++ {
+		if v[i] >= utf8.RuneSelf {
+			return mime.BEncoding.Encode("utf-8", v)
+		}
+	}
+	return v
+}
+
+// moveFile will rename src to dst, src will be copied into the dir of dst and
+// renamed instead if they are on different file systems, so that dst is always
+// replaced atomically.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	err = copyFileAtomic(src, dst)
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFileAtomic will copy src into a temporary file in the dir of dst, and
+// rename it to dst once finished.
+func copyFileAtomic(src, dst string) (err error) {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = w.Close()
+			_ = os.Remove(w.Name())
+		}
+	}()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(w.Name(), dst)
+}
+
+// decodeUserMetadataValue will decode user metadata value encoded by encodeUserMetadataValue.
+//
+// Only the `=?utf-8?b?...?=` encoded-words joined by space will be decoded, so
+// that ASCII values which happen to be valid RFC 2047 in other forms are kept.
+func decodeUserMetadataValue(v string) string {
+	for _, w := range strings.Split(v, " ") {
+		if len(w) <= len(userMetadataEncodedPrefix)+len(userMetadataEncodedSuffix) ||
+			!strings.HasPrefix(w, userMetadataEncodedPrefix) || !strings.HasSuffix(w, userMetadataEncodedSuffix) {
+			return v
+		}
+	}
+
+	dv, err := new(mime.WordDecoder).DecodeHeader(v)
+	if err != nil {
+		return v
+	}
+	return dv
+}
+
+// checkReferer will check the referer of RefererConfig, `*` and `?` are allowed
+// as wildcards, so only obvious typos like whitespaces and unsupported schemes
+// will be rejected.
+func checkReferer(v string) error {
+	if v == "" {
+		return fmt.Errorf("referer is empty: %w", services.ErrRestrictionDissatisfied)
+	}
+	if strings.IndexFunc(v, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("referer %q contains whitespaces: %w", v, services.ErrRestrictionDissatisfied)
+	}
+	if i := strings.Index(v, "://"); i >= 0 {
+		if scheme := v[:i]; scheme != "http" && scheme != "https" && scheme != "*" {
+			return fmt.Errorf("referer %q has unsupported scheme %s: %w", v, scheme, services.ErrRestrictionDissatisfied)
+		}
+	}
+	return nil
+}
+
+// formatLastModified will parse the Last-Modified header returned by OSS, like
+// `Wed, 21 Oct 2015 07:28:00 GMT`.
+//
+// The time will be normalized to UTC, so that it's the same as the one returned
+// by List, which is parsed from ISO 8601 like `2015-10-21T07:28:00.000Z`.
+func formatLastModified(v string) (time.Time, error) {
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// formatSystemMetadata will parse system metadata from headers of HEAD or GET object.
+//
+// Object tagging and ACL are not returned in headers, they need to be fetched separately.
+func formatSystemMetadata(h http.Header) ObjectSystemMetadata {
+	var sm ObjectSystemMetadata
+	if v := h.Get(storageClassHeader); v != "" {
+		sm.StorageClass = v
+	}
+	if v := h.Get(serverSideEncryptionHeader); v != "" {
+		sm.ServerSideEncryption = v
+	}
+	if v := h.Get(serverSideEncryptionKeyIdHeader); v != "" {
+		sm.ServerSideEncryptionKeyID = v
+	}
+	if v := h.Get(serverSideDataEncryptionHeader); v != "" {
+		sm.ServerSideDataEncryption = v
+	}
+	if v := h.Get(versionIDHeader); v != "" {
+		sm.VersionID = v
+	}
+	if v := h.Get(oss.HTTPHeaderCacheControl); v != "" {
+		sm.CacheControl = v
+	}
+	if v := h.Get(oss.HTTPHeaderContentDisposition); v != "" {
+		sm.ContentDisposition = v
+	}
+	if v := h.Get(oss.HTTPHeaderContentEncoding); v != "" {
+		sm.ContentEncoding = v
+	}
+	if v := h.Get(oss.HTTPHeaderOssCRC64); v != "" {
+		if crc, err := strconv.ParseUint(v, 10, 64); err == nil {
+			sm.HashCrc64 = crc
+		}
+	}
+	// OSS stores Expires as RFC1123 which is the same as http.TimeFormat.
+	// Invalid values like 0 are allowed in Expires, which means already expired,
+	// so we just ignore them.
+	if v := h.Get(oss.HTTPHeaderExpires); v != "" {
+		if expires, err := http.ParseTime(v); err == nil {
+			sm.Expires = expires
+		}
+	}
+	return sm
+}
+
+// formatUserMetadata will parse user metadata from headers.
+func formatUserMetadata(h http.Header) map[string]string {
+	var um map[string]string
+
+	for k := range h {
+		lk := strings.ToLower(k)
+		if !strings.HasPrefix(lk, userMetadataPrefix) {
+			continue
+		}
+		if um == nil {
+			um = make(map[string]string)
+		}
+
+		um[strings.TrimPrefix(lk, userMetadataPrefix)] = decodeUserMetadataValue(h.Get(k))
+	}
+	return um
+}
+
+// formatCopyMetadataOptions will convert the metadata pairs of Copy into options,
+// which are only used with MetadataDirectiveReplace.
+func formatCopyMetadataOptions(opt pairStorageCopy) []oss.Option {
+	var options []oss.Option
+	if opt.HasContentType && opt.ContentType != "" {
+		options = append(options, oss.ContentType(opt.ContentType))
+	}
+	if opt.HasCacheControl {
+		options = append(options, oss.CacheControl(opt.CacheControl))
+	}
+	if opt.HasContentDisposition {
+		options = append(options, oss.ContentDisposition(opt.ContentDisposition))
+	}
+	if opt.HasContentEncoding {
+		options = append(options, oss.ContentEncoding(opt.ContentEncoding))
+	}
+	if opt.HasExpires {
+		// http.TimeFormat requires the time to be in UTC.
+		options = append(options, oss.Expires(opt.Expires.UTC()))
+	}
+	for k, v := range opt.UserMetadata {
+		options = append(options, oss.Meta(strings.ToLower(k), encodeUserMetadataValue(v)))
+	}
+	return options
+}
+
+// formatSourceMetadataOptions will convert the metadata in headers of the src object
+// into options, so that they can be carried over by multipart copy.
+func formatSourceMetadataOptions(h http.Header) []oss.Option {
+	var options []oss.Option
+	if v := h.Get(oss.HTTPHeaderContentType); v != "" {
+		options = append(options, oss.ContentType(v))
+	}
+	if v := h.Get(oss.HTTPHeaderCacheControl); v != "" {
+		options = append(options, oss.CacheControl(v))
+	}
+	if v := h.Get(oss.HTTPHeaderContentDisposition); v != "" {
+		options = append(options, oss.ContentDisposition(v))
+	}
+	if v := h.Get(oss.HTTPHeaderContentEncoding); v != "" {
+		options = append(options, oss.ContentEncoding(v))
+	}
+	if v := h.Get(oss.HTTPHeaderExpires); v != "" {
+		if expires, err := http.ParseTime(v); err == nil {
+			options = append(options, oss.Expires(expires))
+		}
+	}
+	for k := range h {
+		lk := strings.ToLower(k)
+		if !strings.HasPrefix(lk, userMetadataPrefix) {
+			continue
+		}
+		// The values have been encoded already, keep them as is.
+		options = append(options, oss.Meta(strings.TrimPrefix(lk, userMetadataPrefix), h.Get(k)))
+	}
+	return options
+}
+
+// serverSideEncryptionPairs are the server side encryption pairs shared by
+// write, create_append and create_multipart.
+type serverSideEncryptionPairs struct {
+	HasServerSideEncryption               bool
+	ServerSideEncryption                  string
+	HasServerSideDataEncryption           bool
+	ServerSideDataEncryption              string
+	HasServerSideEncryptionKeyID          bool
+	ServerSideEncryptionKeyID             string
+	HasServerSideEncryptionCustomerKey    bool
+	ServerSideEncryptionCustomerKey       []byte
+	HasServerSideEncryptionCustomerKeyMd5 bool
+	ServerSideEncryptionCustomerKeyMd5    string
+}
+
+// formatServerSideEncryptionOptions will validate the server side encryption
+// pairs and convert them into options.
+func formatServerSideEncryptionOptions(p serverSideEncryptionPairs) ([]oss.Option, error) {
+	// server_side_data_encryption and server_side_encryption_key_id are only valid when server_side_encryption is KMS,
+	// and SM4 is the only data encryption algorithm supported for now.
+	if p.HasServerSideDataEncryption &&
+		(p.ServerSideEncryption != ServerSideEncryptionKMS || p.ServerSideDataEncryption != ServerSideDataEncryptionSM4) {
+		return nil, services.PairUnsupportedError{Pair: WithServerSideDataEncryption(p.ServerSideDataEncryption)}
+	}
+	if p.HasServerSideEncryptionKeyID && p.ServerSideEncryption != ServerSideEncryptionKMS {
+		return nil, services.PairUnsupportedError{Pair: WithServerSideEncryptionKeyID(p.ServerSideEncryptionKeyID)}
+	}
+	// SSE-C can't be used with the other server side encryption.
+	if p.HasServerSideEncryption && (p.HasServerSideEncryptionCustomerKey || p.HasServerSideEncryptionCustomerKeyMd5) {
+		return nil, services.PairUnsupportedError{Pair: WithServerSideEncryption(p.ServerSideEncryption)}
+	}
+
+	options := make([]oss.Option, 0, 3)
+	if p.HasServerSideEncryption {
+		options = append(options, oss.ServerSideEncryption(p.ServerSideEncryption))
+	}
+	if p.HasServerSideDataEncryption {
+		options = append(options, oss.ServerSideDataEncryption(p.ServerSideDataEncryption))
+	}
+	if p.HasServerSideEncryptionKeyID {
+		options = append(options, oss.ServerSideEncryptionKeyID(p.ServerSideEncryptionKeyID))
+	}
+	if p.HasServerSideEncryptionCustomerKey || p.HasServerSideEncryptionCustomerKeyMd5 {
+		sseOptions, err := formatServerSideEncryptionCustomerOptions(p.ServerSideEncryptionCustomerKey, p.ServerSideEncryptionCustomerKeyMd5)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sseOptions...)
+	}
+	return options, nil
+}
+
+// formatServerSideEncryptionCustomerOptions will convert the customer-provided
+// key into SSE-C headers, the key MD5 will be computed if not set.
+//
+// ref: https://help.aliyun.com/document_detail/31871.html
+func formatServerSideEncryptionCustomerOptions(key []byte, keyMd5 string) ([]oss.Option, error) {
+	if len(key) != serverSideEncryptionCustomerKeySize {
+		return nil, fmt.Errorf("server side encryption customer key must be %d bytes, got %d: %w",
+			serverSideEncryptionCustomerKeySize, len(key), services.ErrRestrictionDissatisfied)
+	}
+
+	sum := md5.Sum(key)
+	computed := base64.StdEncoding.EncodeToString(sum[:])
+	if keyMd5 != "" && keyMd5 != computed {
+		return nil, fmt.Errorf("server side encryption customer key md5 %s doesn't match the key: %w",
+			keyMd5, services.ErrRestrictionDissatisfied)
+	}
+
+	return []oss.Option{
+		oss.SSECAlgorithm(ServerSideEncryptionAES256),
+		oss.SSECKey(base64.StdEncoding.EncodeToString(key)),
+		oss.SSECKeyMd5(computed),
+	}, nil
+}
+
+// formatCopySourceServerSideEncryptionCustomerOptions will convert the
+// customer-provided key into the SSE-C headers of the copy source, which are
+// required while copying an object encrypted with SSE-C.
+//
+// ref: https://help.aliyun.com/document_detail/31871.html
+func formatCopySourceServerSideEncryptionCustomerOptions(key []byte, keyMd5 string) ([]oss.Option, error) {
+	// The key is validated in the same way as the one of the destination.
+	_, err := formatServerSideEncryptionCustomerOptions(key, keyMd5)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum(key)
+	return []oss.Option{
+		oss.SetHeader(copySourceSSECAlgorithmHeader, ServerSideEncryptionAES256),
+		oss.SetHeader(copySourceSSECKeyHeader, base64.StdEncoding.EncodeToString(key)),
+		oss.SetHeader(copySourceSSECKeyMd5Header, base64.StdEncoding.EncodeToString(sum[:])),
+	}, nil
+}
+
+// formatSourceServerSideEncryptionOptions will carry over the server side
+// encryption of the source object, which will be dropped by a copy otherwise.
+//
+// SSE-C can't be carried over, the customer-provided key must be passed again.
+func formatSourceServerSideEncryptionOptions(h http.Header) []oss.Option {
+	var options []oss.Option
+	if v := h.Get(serverSideEncryptionHeader); v != "" {
+		options = append(options, oss.ServerSideEncryption(v))
+	}
+	if v := h.Get(serverSideDataEncryptionHeader); v != "" {
+		options = append(options, oss.ServerSideDataEncryption(v))
+	}
+	if v := h.Get(serverSideEncryptionKeyIdHeader); v != "" {
+		options = append(options, oss.ServerSideEncryptionKeyID(v))
+	}
+	return options
+}
+
+// formatObjectTagging will convert tags map into oss.Tagging.
+//
+// Tags are sorted by key so that the generated `x-oss-tagging` header is stable,
+// the URL-encoding of keys and values will be handled by oss.SetTagging.
+func formatObjectTagging(tags map[string]string) oss.Tagging {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tagging := oss.Tagging{
+		Tags: make([]oss.Tag, 0, len(keys)),
+	}
+	for _, k := range keys {
+		tagging.Tags = append(tagging.Tags, oss.Tag{
+			Key:   k,
+			Value: tags[k],
+		})
+	}
+	return tagging
+}
+
+const (
+	lifecycleStatusEnabled  = "Enabled"
+	lifecycleStatusDisabled = "Disabled"
+)
+
+// formatLifecycleRules will convert lifecycle rules into oss.LifecycleRule.
+func formatLifecycleRules(rules []LifecycleRule) []oss.LifecycleRule {
+	output := make([]oss.LifecycleRule, 0, len(rules))
+	for _, v := range rules {
+		rule := oss.LifecycleRule{
+			ID:     v.ID,
+			Prefix: v.Prefix,
+			Status: lifecycleStatusDisabled,
+		}
+		if v.Enabled {
+			rule.Status = lifecycleStatusEnabled
+		}
+		if v.ExpirationDays > 0 {
+			rule.Expiration = &oss.LifecycleExpiration{Days: v.ExpirationDays}
+		}
+		if v.AbortMultipartUploadDays > 0 {
+			rule.AbortMultipartUpload = &oss.LifecycleAbortMultipartUpload{Days: v.AbortMultipartUploadDays}
+		}
+		for _, t := range v.Transitions {
+			rule.Transitions = append(rule.Transitions, oss.LifecycleTransition{
+				Days:         t.Days,
+				StorageClass: oss.StorageClassType(t.StorageClass),
+			})
+		}
+		output = append(output, rule)
+	}
+	return output
+}
+
+// parseLifecycleRules will convert oss.LifecycleRule into lifecycle rules.
+//
+// Rules based on CreatedBeforeDate are not supported, their dates will be ignored.
+func parseLifecycleRules(rules []oss.LifecycleRule) []LifecycleRule {
+	output := make([]LifecycleRule, 0, len(rules))
+	for _, v := range rules {
+		rule := LifecycleRule{
+			ID:      v.ID,
+			Prefix:  v.Prefix,
+			Enabled: v.Status == lifecycleStatusEnabled,
+		}
+		if v.Expiration != nil {
+			rule.ExpirationDays = v.Expiration.Days
+		}
+		if v.AbortMultipartUpload != nil {
+			rule.AbortMultipartUploadDays = v.AbortMultipartUpload.Days
+		}
+		for _, t := range v.Transitions {
+			rule.Transitions = append(rule.Transitions, LifecycleTransition{
+				Days:         t.Days,
+				StorageClass: string(t.StorageClass),
+			})
+		}
+		output = append(output, rule)
+	}
+	return output
+}
+
+// formatWebsiteConfig will convert WebsiteConfig into oss.WebsiteXML.
+func formatWebsiteConfig(cfg WebsiteConfig) oss.WebsiteXML {
+	output := oss.WebsiteXML{
+		IndexDocument: oss.IndexDocument{Suffix: cfg.IndexDocument},
+		ErrorDocument: oss.ErrorDocument{Key: cfg.ErrorDocument},
+	}
+	for _, v := range cfg.RoutingRules {
+		rule := oss.RoutingRule{
+			RuleNumber: v.RuleNumber,
+			Condition: oss.Condition{
+				KeyPrefixEquals:             v.Condition.KeyPrefixEquals,
+				HTTPErrorCodeReturnedEquals: v.Condition.HTTPErrorCodeReturnedEquals,
+			},
+			Redirect: oss.Redirect{
+				RedirectType:         v.Redirect.Type,
+				MirrorURL:            v.Redirect.MirrorURL,
+				Protocol:             v.Redirect.Protocol,
+				HostName:             v.Redirect.HostName,
+				ReplaceKeyPrefixWith: v.Redirect.ReplaceKeyPrefixWith,
+				ReplaceKeyWith:       v.Redirect.ReplaceKeyWith,
+				HttpRedirectCode:     v.Redirect.HTTPRedirectCode,
+			},
+		}
+		// Sort the headers so that the generated XML is stable.
+		keys := make([]string, 0, len(v.Condition.IncludeHeaders))
+		for k := range v.Condition.IncludeHeaders {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rule.Condition.IncludeHeader = append(rule.Condition.IncludeHeader, oss.IncludeHeader{
+				Key:    k,
+				Equals: v.Condition.IncludeHeaders[k],
+			})
+		}
+		if v.Redirect.PassQueryString {
+			passQueryString := true
+			rule.Redirect.PassQueryString = &passQueryString
+		}
+		output.RoutingRules = append(output.RoutingRules, rule)
+	}
+	return output
+}
+
+// parseWebsiteConfig will convert oss.WebsiteXML into WebsiteConfig.
+//
+// Mirror specific settings like MirrorHeaders are not supported, they will be ignored.
+func parseWebsiteConfig(v oss.WebsiteXML) *WebsiteConfig {
+	output := &WebsiteConfig{
+		IndexDocument: v.IndexDocument.Suffix,
+		ErrorDocument: v.ErrorDocument.Key,
+	}
+	for _, r := range v.RoutingRules {
+		rule := WebsiteRoutingRule{
+			RuleNumber: r.RuleNumber,
+			Condition: WebsiteCondition{
+				KeyPrefixEquals:             r.Condition.KeyPrefixEquals,
+				HTTPErrorCodeReturnedEquals: r.Condition.HTTPErrorCodeReturnedEquals,
+			},
+			Redirect: WebsiteRedirect{
+				Type:                 r.Redirect.RedirectType,
+				PassQueryString:      r.Redirect.PassQueryString != nil && *r.Redirect.PassQueryString,
+				MirrorURL:            r.Redirect.MirrorURL,
+				Protocol:             r.Redirect.Protocol,
+				HostName:             r.Redirect.HostName,
+				ReplaceKeyPrefixWith: r.Redirect.ReplaceKeyPrefixWith,
+				ReplaceKeyWith:       r.Redirect.ReplaceKeyWith,
+				HTTPRedirectCode:     r.Redirect.HttpRedirectCode,
+			},
+		}
+		if len(r.Condition.IncludeHeader) > 0 {
+			rule.Condition.IncludeHeaders = make(map[string]string, len(r.Condition.IncludeHeader))
+			for _, h := range r.Condition.IncludeHeader {
+				rule.Condition.IncludeHeaders[h.Key] = h.Equals
+			}
+		}
+		output.RoutingRules = append(output.RoutingRules, rule)
+	}
+	return output
+}
+
 // OSS response error code.
 //
 // ref: https://error-center.alibabacloud.com/status/product/Oss
 const (
+	// responseCodeNoSuchKey will be returned while the specified object does not exist.
+	responseCodeNoSuchKey = "NoSuchKey"
+	// responseCodeNoSuchBucketPolicy will be returned while getting policy of a bucket which has no policy.
+	responseCodeNoSuchBucketPolicy = "NoSuchBucketPolicy"
+	// responseCodeNoSuchLifecycle will be returned while getting lifecycle of a bucket which has no lifecycle rules.
+	responseCodeNoSuchLifecycle = "NoSuchLifecycle"
+	// responseCodeNoSuchWebsiteConfiguration will be returned while getting website of a bucket which has no website configuration.
+	responseCodeNoSuchWebsiteConfiguration = "NoSuchWebsiteConfiguration"
+	// responseCodeNotSymlink will be returned while getting symlink of an object which is not a symlink.
+	responseCodeNotSymlink = "NotSymlink"
 	// responseCodeNoSuchUpload will be returned while the specified upload does not exist.
 	responseCodeNoSuchUpload = "NoSuchUpload"
+	// responseCodeRestoreAlreadyInProgress will be returned while the object is being restored.
+	responseCodeRestoreAlreadyInProgress = "RestoreAlreadyInProgress"
+	// responseCodeInvalidObjectState will be returned while reading an archived object which has not been restored.
+	responseCodeInvalidObjectState = "InvalidObjectState"
+	// responseCodePositionNotEqualToLength will be returned while the append position doesn't match the object's length.
+	responseCodePositionNotEqualToLength = "PositionNotEqualToLength"
+	// responseCodeBucketNotEmpty will be returned while deleting a bucket which still has objects or multipart uploads.
+	responseCodeBucketNotEmpty = "BucketNotEmpty"
+	// responseCodeBadDigest will be returned while the Content-MD5 doesn't match the content.
+	responseCodeBadDigest = "BadDigest"
+	// responseCodeInvalidDigest will be returned while the Content-MD5 is not a valid base64 encoded MD5.
+	responseCodeInvalidDigest = "InvalidDigest"
+	// responseCodePreconditionFailed will be returned while the object doesn't satisfy the read conditions.
+	responseCodePreconditionFailed = "PreconditionFailed"
+	// responseCodeNoSuchBucket will be returned while the specified bucket does not exist.
+	responseCodeNoSuchBucket = "NoSuchBucket"
+	// responseCodeBucketAlreadyExists will be returned while creating a bucket which has been taken.
+	responseCodeBucketAlreadyExists = "BucketAlreadyExists"
+	// responseCodeSignatureDoesNotMatch will be returned while the secret key is wrong.
+	responseCodeSignatureDoesNotMatch = "SignatureDoesNotMatch"
+	// responseCodeInvalidAccessKeyID will be returned while the access key does not exist or is disabled.
+	responseCodeInvalidAccessKeyID = "InvalidAccessKeyId"
+	// responseCodeRequestTimeTooSkewed will be returned while the local time differs from server for more than 15 minutes.
+	responseCodeRequestTimeTooSkewed = "RequestTimeTooSkewed"
+	// responseCodeInvalidBucketName will be returned while the bucket name is invalid.
+	responseCodeInvalidBucketName = "InvalidBucketName"
+	// responseCodeFileAlreadyExists will be returned while the target object exists with x-oss-forbid-overwrite.
+	responseCodeFileAlreadyExists = "FileAlreadyExists"
+	// responseCodeInternalError will be returned while OSS has an internal error.
+	responseCodeInternalError = "InternalError"
+	// responseCodeSlowDown will be returned while the requests are throttled by OSS.
+	responseCodeSlowDown = "SlowDown"
+	// responseCodeServiceUnavailable will be returned while OSS is overloaded.
+	responseCodeServiceUnavailable = "ServiceUnavailable"
 )
 
+// throttledDelayMinimum is the minimum base delay to retry throttled requests.
+const throttledDelayMinimum = 100 * time.Millisecond
+
+// RetryPolicy is the retry policy for idempotent operations.
+//
+// The delay before the nth retry is a random duration in [0, min(MaxDelay, BaseDelay * 2^n)).
+// Requests throttled by OSS (503) use at least 100ms as BaseDelay.
+type RetryPolicy struct {
+	// MaxAttempts is the max attempts including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+	// MaxDelay is the max delay between two attempts, no limit if it's zero.
+	MaxDelay time.Duration
+}
+
+// retry will call fn until it succeeds, returns an error which can't be retried or
+// reaches the max attempts. fn will be called only once if policy is empty.
+func (s *Storage) retry(ctx context.Context, policy RetryPolicy, fn func() error) (err error) {
+	return s.retryIf(ctx, policy, isRetryableError, fn)
+}
+
+// retryIf is the same as retry, but only the errors accepted by retryable will be retried.
+func (s *Storage) retryIf(ctx context.Context, policy RetryPolicy, retryable func(error) bool, fn func() error) (err error) {
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || attempt+1 >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		// Retrying throttled requests immediately makes the throttling worse, so
+		// back off for a while even if no base delay is set.
+		baseDelay := policy.BaseDelay
+		if baseDelay < throttledDelayMinimum && isThrottledError(err) {
+			baseDelay = throttledDelayMinimum
+		}
+		delay := baseDelay << uint(attempt)
+		if policy.MaxDelay > 0 && (delay <= 0 || delay > policy.MaxDelay) {
+			delay = policy.MaxDelay
+		}
+		if delay > 0 {
+			delay = time.Duration(rand.Int63n(int64(delay)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isThrottledError will check whether the request is throttled by OSS.
+func isThrottledError(err error) bool {
+	switch e := err.(type) {
+	case oss.ServiceError:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case oss.UnexpectedStatusCodeError:
+		return e.Got() == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+// isRetryableError will check whether the error is caused by server or network,
+// errors caused by client (4xx) should not be retried.
+func isRetryableError(err error) bool {
+	switch e := err.(type) {
+	case oss.ServiceError:
+		return e.StatusCode >= 500
+	case oss.UnexpectedStatusCodeError:
+		return e.Got() >= 500
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// progressListener will adapt progress_callback to oss.ProgressListener.
+type progressListener func(completed, total int64)
+
+// ProgressChanged implements oss.ProgressListener
+func (fn progressListener) ProgressChanged(event *oss.ProgressEvent) {
+	fn(event.ConsumedBytes, event.TotalBytes)
+}
+
+// contextReader will return the context's error once the context is done.
+//
+// OSS SDK doesn't support context, so we use it to abort the request while reading
+// or writing the body.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// sizeCheckReader will return ErrSizeMismatch instead of io.EOF if r ends
+// before size bytes have been read.
+//
+// Without it, net/http will fail with an opaque error about the Content-Length.
+type sizeCheckReader struct {
+	r    io.Reader
+	size int64
+	n    int64
+	err  *error
+}
+
+func (r *sizeCheckReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if err == io.EOF && r.n < r.size {
+		err = fmt.Errorf("%w: expected %d bytes but reader ended at %d bytes", ErrSizeMismatch, r.size, r.n)
+		*r.err = err
+	}
+	return n, err
+}
+
+// isNotFoundError will check whether the error is caused by the object not exist.
+//
+// HEAD requests don't have a response body, so only the status code is available.
+func isNotFoundError(err error) bool {
+	e, ok := err.(oss.ServiceError)
+	return ok && e.StatusCode == 404
+}
+
 func checkError(err error, code string) bool {
 	e, ok := err.(oss.ServiceError)
 	if !ok {
@@ -322,8 +1594,50 @@ const (
 	multipartSizeMaximum = 5 * 1024 * 1024 * 1024
 	// multipartSizeMinimum is the minimum size for each part, 100KB.
 	multipartSizeMinimum = 100 * 1024
+	// multipartBufferMaximum is the maximum size of parts buffered by UploadMultipart, 256MB.
+	multipartBufferMaximum = 256 * 1024 * 1024
+	// writeStreamProbeSize is the size read before allocating a whole part for streams, 64KB.
+	writeStreamProbeSize = 64 * 1024
+	// resumablePartSizeDefault is the default part size used by UploadFile, 8MB.
+	resumablePartSizeDefault = 8 * 1024 * 1024
 )
 
+// CalculatePartSize will calculate the part size for a multipart upload of the
+// given total size.
+//
+// The default part size 8MB will be used if the part count doesn't exceed the limit,
+// otherwise the part size will be increased to keep the part count under 10000, and
+// rounded up to a multiple of 1MB. ErrRestrictionDissatisfied will be returned if
+// the total size exceeds 10000 parts of 5GB.
+func CalculatePartSize(size int64) (int64, error) {
+	if size < 0 {
+		return 0, fmt.Errorf("size %d is negative: %w", size, services.ErrRestrictionDissatisfied)
+	}
+	if size > multipartNumberMaximum*multipartSizeMaximum {
+		return 0, fmt.Errorf("size %d exceeds the maximum %d: %w",
+			size, int64(multipartNumberMaximum*multipartSizeMaximum), services.ErrRestrictionDissatisfied)
+	}
+
+	partSize := int64(resumablePartSizeDefault)
+	if size <= partSize*multipartNumberMaximum {
+		return partSize, nil
+	}
+
+	const mb = 1024 * 1024
+	partSize = (size + multipartNumberMaximum - 1) / multipartNumberMaximum
+	partSize = (partSize + mb - 1) / mb * mb
+	if partSize > multipartSizeMaximum {
+		partSize = multipartSizeMaximum
+	}
+	return partSize, nil
+}
+
+// listPageSizeMaximum is the maximum keys returned in one page of ListObjects.
+const listPageSizeMaximum = 1000
+
+// symlinkDepthMaximum is the maximum levels of symlinks followed by follow_symlink.
+const symlinkDepthMaximum = 8
+
 const (
 	// writeSizeMaximum is the maximum size for each object with a single PUT operation, 5GB.
 	// ref: https://help.aliyun.com/document_detail/31978.html#title-gkg-amg-aes
@@ -331,4 +1645,25 @@ const (
 	// appendSizeMaximum is the total maximum size for an append object, 5GB.
 	// ref: https://help.aliyun.com/document_detail/31981.html?spm=a2c4g.11186623.6.1684.479a3ea7S8dRgB#title-22f-5c3-0sv
 	appendTotalSizeMaximum = 5 * 1024 * 1024 * 1024
+	// bucketNameLengthMinimum and bucketNameLengthMaximum are the length limits of bucket name.
+	// ref: https://help.aliyun.com/document_detail/31885.html
+	bucketNameLengthMinimum = 3
+	bucketNameLengthMaximum = 63
+	// serverSideEncryptionCustomerKeySize is the size of customer-provided key for SSE-C, AES256 only.
+	serverSideEncryptionCustomerKeySize = 32
+	// copySizeMaximum is the maximum size for each object with a single CopyObject operation, 1GB.
+	// ref: https://help.aliyun.com/document_detail/31979.html
+	copySizeMaximum = 1 * 1024 * 1024 * 1024
+	// deleteObjectsMaximum is the maximum count of objects with a single DeleteObjects operation.
+	// ref: https://help.aliyun.com/document_detail/31983.html
+	deleteObjectsMaximum = 1000
+)
+
+// trafficLimitXXX are the traffic limit restriction in OSS, see more details at:
+// https://help.aliyun.com/document_detail/383750.html
+const (
+	// trafficLimitMinimum is the minimum traffic limit, 100KB/s.
+	trafficLimitMinimum = 100 * 1024 * 8
+	// trafficLimitMaximum is the maximum traffic limit, 100MB/s.
+	trafficLimitMaximum = 100 * 1024 * 1024 * 8
 )
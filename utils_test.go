@@ -0,0 +1,2840 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+
+	ps "github.com/beyondstorage/go-storage/v4/pairs"
+	"github.com/beyondstorage/go-storage/v4/services"
+	"github.com/beyondstorage/go-storage/v4/types"
+)
+
+// newTestStorage will create a storage of test-bucket which sends all requests
+// to h, pairs are applied before the default ones so that they can be overridden.
+func newTestStorage(t *testing.T, h http.HandlerFunc, pairs ...types.Pair) *Storage {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	_, store, err := newServicerAndStorager(append(pairs,
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("http:"+srv.Listener.Addr().String()),
+		ps.WithName("test-bucket"),
+	)...)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	return store
+}
+
+// newTestService will create a service which sends all requests to h.
+func newTestService(t *testing.T, h http.HandlerFunc) *Service {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	service, err := newServicer(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("http:"+srv.Listener.Addr().String()),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	return service
+}
+
+func TestFormatWorkDir(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+		hasErr   bool
+	}{
+		{"empty", "", "/", false},
+		{"root", "/", "/", false},
+		{"without trailing slash", "foo", "/foo/", false},
+		{"with trailing slash", "foo/", "/foo/", false},
+		{"absolute", "/foo/bar", "/foo/bar/", false},
+		{"redundant slashes", "//foo//bar//", "/foo/bar/", false},
+		{"parent dir", "/foo/../bar", "", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir, err := formatWorkDir(tt.input)
+			if tt.hasErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", workDir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("format work dir: %v", err)
+			}
+			if workDir != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, workDir)
+			}
+		})
+	}
+}
+
+func TestGetAbsPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		workDir  string
+		path     string
+		expected string
+	}{
+		{"root", "/", "bar.txt", "bar.txt"},
+		{"without trailing slash", "foo", "bar.txt", "foo/bar.txt"},
+		{"with trailing slash", "foo/", "bar.txt", "foo/bar.txt"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir, err := formatWorkDir(tt.workDir)
+			if err != nil {
+				t.Fatalf("format work dir: %v", err)
+			}
+
+			s := &Storage{workDir: workDir}
+			if v, err := s.getAbsPath(tt.path); err != nil || v != tt.expected {
+				t.Errorf("expected %s, got %s, %v", tt.expected, v, err)
+			}
+			if v := s.getRelPath(tt.expected); v != tt.path {
+				t.Errorf("expected %s, got %s", tt.path, v)
+			}
+		})
+	}
+}
+
+func TestGetAbsPathSanitize(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		expected string
+		err      error
+	}{
+		{"empty", "", "foo/", nil},
+		{"leading slash", "/bar.txt", "foo/bar.txt", nil},
+		{"parent dir", "../bar.txt", "", services.ErrRestrictionDissatisfied},
+		{"nested parent dir", "a/../../bar.txt", "", services.ErrRestrictionDissatisfied},
+		{"parent dir only", "/..", "", services.ErrRestrictionDissatisfied},
+		{"parent dir inside", "a/../bar.txt", "foo/a/../bar.txt", nil},
+		{"dots in name", "..bar.txt", "foo/..bar.txt", nil},
+		{"redundant slashes", "a///b.txt", "foo/a/b.txt", nil},
+		{"redundant trailing slashes", "a/b//", "foo/a/b/", nil},
+		{"current dir", "a/./b.txt", "foo/a/./b.txt", nil},
+		{"trailing slash", "a/b/", "foo/a/b/", nil},
+	}
+
+	s := &Storage{workDir: "/foo/"}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := s.getAbsPath(tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if v != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, v)
+			}
+			if err == nil && "foo/"+s.getRelPath(v) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, "foo/"+s.getRelPath(v))
+			}
+		})
+	}
+}
+
+func TestCreateOutsideWorkDir(t *testing.T) {
+	s := &Storage{workDir: "/foo/"}
+
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, services.ErrRestrictionDissatisfied) {
+			t.Errorf("expected restriction dissatisfied panic, got %v", err)
+		}
+	}()
+	s.Create("../bar.txt")
+	t.Errorf("expected panic")
+}
+
+func TestFormatErrorRequestID(t *testing.T) {
+	err := formatError(oss.ServiceError{
+		Code:       responseCodeNoSuchKey,
+		RequestID:  "5C3D9175B6FC201293AD4890",
+		HostID:     "test-bucket.oss-cn-hangzhou.aliyuncs.com",
+		StatusCode: 404,
+	})
+
+	if !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected %s, got %s", services.ErrObjectNotExist, err)
+	}
+
+	var re ResponseError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected ResponseError, got %T", err)
+	}
+	if re.RequestID != "5C3D9175B6FC201293AD4890" {
+		t.Errorf("expected %s, got %s", "5C3D9175B6FC201293AD4890", re.RequestID)
+	}
+	if re.HostID != "test-bucket.oss-cn-hangzhou.aliyuncs.com" {
+		t.Errorf("expected %s, got %s", "test-bucket.oss-cn-hangzhou.aliyuncs.com", re.HostID)
+	}
+
+	// Formatted errors should be kept as is.
+	if v := formatError(err); v != err {
+		t.Errorf("expected %s, got %s", err, v)
+	}
+}
+
+func TestFormatErrorEndpointMismatch(t *testing.T) {
+	err := formatError(oss.ServiceError{
+		Code:       "AccessDenied",
+		Message:    "The bucket you are attempting to access must be addressed using the specified endpoint.",
+		Endpoint:   "oss-cn-beijing.aliyuncs.com",
+		StatusCode: 403,
+	})
+
+	if !errors.Is(err, ErrEndpointMismatch) {
+		t.Errorf("expected %s, got %s", ErrEndpointMismatch, err)
+	}
+	if !strings.Contains(err.Error(), "oss-cn-beijing.aliyuncs.com") {
+		t.Errorf("expected endpoint in error, got %s", err)
+	}
+}
+
+func TestNewServicerForceHTTPS(t *testing.T) {
+	cases := []struct {
+		name     string
+		endpoint string
+		hasErr   bool
+	}{
+		{"http", "http:oss-cn-hangzhou.aliyuncs.com", true},
+		{"https", "https:oss-cn-hangzhou.aliyuncs.com", false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &TLSConfig{MinVersion: tls.VersionTLS12}
+			srv, err := newServicer(
+				ps.WithCredential("hmac:ak:sk"),
+				ps.WithEndpoint(tt.endpoint),
+				WithForceHTTPS(),
+				WithTLSConfig(cfg),
+			)
+			if tt.hasErr {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("new servicer: %v", err)
+			}
+
+			tr := srv.service.HTTPClient.Transport.(*http.Transport)
+			if tr.TLSClientConfig != cfg {
+				t.Errorf("expected tls config %p, got %p", cfg, tr.TLSClientConfig)
+			}
+		})
+	}
+}
+
+func TestNewServicerProxyURL(t *testing.T) {
+	var host string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests sent via proxy carry the absolute URL of the target.
+		host = r.URL.Host
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+
+	srv, err := newServicer(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("http:oss-cn-hangzhou.aliyuncs.com"),
+		WithProxyURL(proxy.URL),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+
+	_, _ = srv.service.ListBuckets()
+	if host != "oss-cn-hangzhou.aliyuncs.com" {
+		t.Errorf("expected %s, got %s", "oss-cn-hangzhou.aliyuncs.com", host)
+	}
+}
+
+func TestNewServicerTimeout(t *testing.T) {
+	srv, err := newServicer(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("https:oss-cn-hangzhou.aliyuncs.com"),
+		WithConnectTimeout(500*time.Millisecond),
+		WithReadWriteTimeout(2*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+
+	timeout := srv.service.Config.HTTPTimeout
+	if timeout.ConnectTimeout != time.Second {
+		t.Errorf("expected %s, got %s", time.Second, timeout.ConnectTimeout)
+	}
+	if timeout.ReadWriteTimeout != 2*time.Second {
+		t.Errorf("expected %s, got %s", 2*time.Second, timeout.ReadWriteTimeout)
+	}
+}
+
+func TestFormatSystemMetadataCrc64(t *testing.T) {
+	h := http.Header{}
+	h.Set(oss.HTTPHeaderOssCRC64, "5981764153023615706")
+
+	sm := formatSystemMetadata(h)
+	if sm.HashCrc64 != 5981764153023615706 {
+		t.Errorf("expected %d, got %d", uint64(5981764153023615706), sm.HashCrc64)
+	}
+}
+
+func TestFormatErrorFileAlreadyExists(t *testing.T) {
+	err := formatError(oss.ServiceError{
+		Code:       responseCodeFileAlreadyExists,
+		StatusCode: 409,
+	})
+
+	if !errors.Is(err, ErrObjectAlreadyExists) {
+		t.Errorf("expected %s, got %s", ErrObjectAlreadyExists, err)
+	}
+}
+
+func TestWriteStorageClass(t *testing.T) {
+	var storageClass string
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		storageClass = r.Header.Get("X-Oss-Storage-Class")
+	})
+
+	_, err := store.Write("archive.tar", strings.NewReader("x"), 1, WithStorageClass(StorageClassIA))
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if storageClass != StorageClassIA {
+		t.Errorf("expected %s, got %s", StorageClassIA, storageClass)
+	}
+}
+
+func TestCopyStorageClass(t *testing.T) {
+	cases := []struct {
+		name         string
+		storageClass string
+		expected     string
+	}{
+		{"preserve source", "", StorageClassArchive},
+		{"storage_class", StorageClassIA, StorageClassIA},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var storageClass string
+			store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					w.Header().Set("Content-Length", "1")
+					w.Header().Set("X-Oss-Storage-Class", StorageClassArchive)
+					return
+				}
+				storageClass = r.Header.Get("X-Oss-Storage-Class")
+				_, _ = w.Write([]byte(`<CopyObjectResult><ETag>"abc"</ETag></CopyObjectResult>`))
+			})
+
+			var err error
+			if tt.storageClass == "" {
+				err = store.Copy("src", "dst")
+			} else {
+				err = store.Copy("src", "dst", WithStorageClass(tt.storageClass))
+			}
+			if err != nil {
+				t.Fatalf("copy: %v", err)
+			}
+			if storageClass != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, storageClass)
+			}
+		})
+	}
+}
+
+func TestFormatErrorSignatureMismatch(t *testing.T) {
+	err := formatError(oss.ServiceError{
+		Code:       responseCodeSignatureDoesNotMatch,
+		StatusCode: 403,
+	})
+
+	if !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("expected %s, got %s", ErrSignatureMismatch, err)
+	}
+	if errors.Is(err, services.ErrPermissionDenied) {
+		t.Errorf("expected not %s, got %s", services.ErrPermissionDenied, err)
+	}
+	if !strings.Contains(err.Error(), responseCodeSignatureDoesNotMatch) {
+		t.Errorf("expected code in error, got %s", err)
+	}
+}
+
+func TestNewStorageInheritFeatures(t *testing.T) {
+	srv, err := newServicer(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("https:oss-cn-hangzhou.aliyuncs.com"),
+		WithEnableVirtualDir(),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+
+	store, err := srv.newStorage(ps.WithName("test-bucket"))
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	if !store.features.VirtualDir {
+		t.Errorf("expected virtual dir enabled")
+	}
+
+	// storage_features takes precedence over the service features.
+	store, err = srv.newStorage(ps.WithName("test-bucket"), WithStorageFeatures(StorageFeatures{}))
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	if store.features.VirtualDir {
+		t.Errorf("expected virtual dir disabled")
+	}
+}
+
+func TestLoosePair(t *testing.T) {
+	cases := []struct {
+		name   string
+		pairs  []types.Pair
+		hasErr bool
+	}{
+		{"strict", nil, true},
+		{"loose", []types.Pair{WithEnableLoosePair()}, false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, store, err := newServicerAndStorager(append([]types.Pair{
+				ps.WithCredential("hmac:ak:sk"),
+				ps.WithEndpoint("https:oss-cn-hangzhou.aliyuncs.com"),
+				ps.WithName("test-bucket"),
+			}, tt.pairs...)...)
+			if err != nil {
+				t.Fatalf("new storager: %v", err)
+			}
+
+			// offset is not supported by write.
+			_, err = store.parsePairStorageWrite([]types.Pair{ps.WithOffset(1)})
+			if tt.hasErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.hasErr, err)
+			}
+			_, err = store.parsePairStorageWriteFile([]types.Pair{ps.WithOffset(1)}, nil)
+			if tt.hasErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestNewStorageDefaultPairs(t *testing.T) {
+	_, store, err := newServicerAndStorager(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("https:oss-cn-hangzhou.aliyuncs.com"),
+		ps.WithName("test-bucket"),
+		WithDefaultListPageSize(100),
+		WithDefaultRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	opt, err := store.parsePairStorageList(store.defaultPairs.List)
+	if err != nil {
+		t.Fatalf("parse list pairs: %v", err)
+	}
+	if opt.ListPageSize != 100 {
+		t.Errorf("expected %d, got %d", 100, opt.ListPageSize)
+	}
+
+	for name, pairs := range map[string][]types.Pair{
+		"delete": store.defaultPairs.Delete,
+		"stat":   store.defaultPairs.Stat,
+	} {
+		if len(pairs) != 1 || pairs[0].Key != "retry_policy" {
+			t.Errorf("%s: expected default retry_policy, got %v", name, pairs)
+		}
+	}
+}
+
+func TestParsePairStorageWriteFileDefaults(t *testing.T) {
+	_, store, err := newServicerAndStorager(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("https:oss-cn-hangzhou.aliyuncs.com"),
+		ps.WithName("test-bucket"),
+		ps.WithDefaultContentType("application/octet-stream"),
+		WithDefaultRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithDefaultStoragePairs(DefaultStoragePairs{
+			Write: []types.Pair{WithStorageClass(StorageClassIA)},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	// retry_policy is not supported by write_file, it should be skipped
+	// instead of returning an error.
+	opt, err := store.parsePairStorageWriteFile(nil, store.defaultPairs.Write)
+	if err != nil {
+		t.Fatalf("parse write_file pairs: %v", err)
+	}
+	if opt.StorageClass != StorageClassIA {
+		t.Errorf("expected %s, got %s", StorageClassIA, opt.StorageClass)
+	}
+	if opt.HasContentType {
+		t.Errorf("expected content type to be inferred, got %s", opt.ContentType)
+	}
+
+	opt, err = store.parsePairStorageWriteFile([]types.Pair{WithStorageClass(StorageClassArchive)}, store.defaultPairs.Write)
+	if err != nil {
+		t.Fatalf("parse write_file pairs: %v", err)
+	}
+	if opt.StorageClass != StorageClassArchive {
+		t.Errorf("expected %s, got %s", StorageClassArchive, opt.StorageClass)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	var header http.Header
+	var body string
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}, WithDefaultStoragePairs(DefaultStoragePairs{
+		Write: []types.Pair{WithServerSideEncryption(ServerSideEncryptionKMS)},
+	}))
+
+	dir, err := ioutil.TempDir("", "oss")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "index.html")
+	err = ioutil.WriteFile(filePath, []byte("<html></html>"), 0644)
+	if err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	n, err := store.WriteFile("index.html", filePath,
+		ps.WithContentType("text/plain"),
+		WithStorageClass(StorageClassIA),
+		WithServerSideEncryptionKeyID("kms-key"),
+		WithUserMetadata(map[string]string{"owner": "alice"}),
+	)
+	if err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if n != int64(len(body)) || body != "<html></html>" {
+		t.Errorf("unexpected body %q with size %d", body, n)
+	}
+	cases := map[string]string{
+		"Content-Type":                        "text/plain",
+		"X-Oss-Storage-Class":                 StorageClassIA,
+		"X-Oss-Server-Side-Encryption":        ServerSideEncryptionKMS,
+		"X-Oss-Server-Side-Encryption-Key-Id": "kms-key",
+		"X-Oss-Meta-Owner":                    "alice",
+	}
+	for k, v := range cases {
+		if got := header.Get(k); got != v {
+			t.Errorf("%s: expected %s, got %s", k, v, got)
+		}
+	}
+
+	// The key id is only valid for KMS.
+	_, err = store.WriteFile("index.html", filePath,
+		WithServerSideEncryption(ServerSideEncryptionAES256), WithServerSideEncryptionKeyID("kms-key"))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected capability insufficient, got %v", err)
+	}
+}
+
+func TestNewServicerAnonymous(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header["Authorization"]
+		_, _ = w.Write([]byte("public"))
+	}))
+	defer srv.Close()
+
+	_, store, err := newServicerAndStorager(
+		ps.WithEndpoint("http:"+srv.Listener.Addr().String()),
+		ps.WithName("test-bucket"),
+		WithAnonymous(),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	var buf strings.Builder
+	_, err = store.Read("public.txt", &buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(auth) != 0 {
+		t.Errorf("expected no authorization, got %v", auth)
+	}
+	if buf.String() != "public" {
+		t.Errorf("expected %s, got %s", "public", buf.String())
+	}
+
+	// credential can't be used with anonymous.
+	_, err = newServicer(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("http:"+srv.Listener.Addr().String()),
+		WithAnonymous(),
+	)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestExists(t *testing.T) {
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("versionId") {
+		case "":
+		case "v1":
+			w.WriteHeader(http.StatusOK)
+			return
+		case "delete-marker":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Path {
+		case "/test-bucket/exist":
+			w.WriteHeader(http.StatusOK)
+		case "/test-bucket/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	cases := []struct {
+		name     string
+		path     string
+		pairs    []types.Pair
+		expected bool
+		hasErr   bool
+	}{
+		{"exist", "exist", nil, true, false},
+		{"not exist", "not-exist", nil, false, false},
+		{"forbidden", "forbidden", nil, false, true},
+		{"version exist", "versioned", []types.Pair{WithVersionID("v1")}, true, false},
+		{"version not exist", "versioned", []types.Pair{WithVersionID("v0")}, false, false},
+		{"version is delete marker", "versioned", []types.Pair{WithVersionID("delete-marker")}, false, false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := store.Exists(tt.path, tt.pairs...)
+			if tt.hasErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.hasErr, err)
+			}
+			if ok != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestReadFollowSymlink(t *testing.T) {
+	targets := map[string]string{
+		"latest": "v2",
+		"v2":     "v2.txt",
+		"loop":   "loop",
+	}
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/test-bucket/")
+		if _, ok := r.URL.Query()["symlink"]; ok {
+			target, ok := targets[key]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("<Error><Code>NotSymlink</Code></Error>"))
+				return
+			}
+			w.Header().Set(oss.HTTPHeaderOssSymlinkTarget, target)
+			return
+		}
+		_, _ = w.Write([]byte(key))
+	})
+
+	var buf strings.Builder
+	_, err := store.Read("latest", &buf, WithFollowSymlink())
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != "v2.txt" {
+		t.Errorf("expected %s, got %s", "v2.txt", buf.String())
+	}
+
+	_, err = store.Read("loop", &buf, WithFollowSymlink())
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected %s, got %v", services.ErrRestrictionDissatisfied, err)
+	}
+}
+
+func TestCalculatePartSize(t *testing.T) {
+	const mb = 1024 * 1024
+	cases := []struct {
+		name     string
+		size     int64
+		expected int64
+		hasErr   bool
+	}{
+		{"empty", 0, 8 * mb, false},
+		{"small", 1024, 8 * mb, false},
+		{"default limit", 8 * mb * 10000, 8 * mb, false},
+		{"larger than default limit", 8*mb*10000 + 1, 9 * mb, false},
+		{"maximum", 10000 * multipartSizeMaximum, multipartSizeMaximum, false},
+		{"too large", 10000*multipartSizeMaximum + 1, 0, true},
+		{"negative", -1, 0, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			partSize, err := CalculatePartSize(tt.size)
+			if tt.hasErr {
+				if err == nil {
+					t.Errorf("expected error, got %d", partSize)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("calculate part size: %v", err)
+			}
+			if partSize != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, partSize)
+			}
+			if (tt.size+partSize-1)/partSize > multipartNumberMaximum {
+				t.Errorf("part count exceeds %d", multipartNumberMaximum)
+			}
+		})
+	}
+}
+
+func TestLastModifiedUTC(t *testing.T) {
+	expected := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+
+	heads := 0
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case len(q["symlink"]) > 0:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("<Error><Code>NotSymlink</Code></Error>"))
+		case len(q["acl"]) > 0:
+			_, _ = w.Write([]byte("<AccessControlPolicy><AccessControlList><Grant>default</Grant></AccessControlList></AccessControlPolicy>"))
+		case r.Method == http.MethodHead:
+			// Objects returned by List will be stated lazily, and keep the fields
+			// from List if stat failed, so only the first HEAD will succeed.
+			heads++
+			if heads > 1 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			// The format of Last-Modified returned by OSS.
+			w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+			w.Header().Set("Content-Length", "3")
+		default:
+			// The format of LastModified returned by ListObjects.
+			_, _ = w.Write([]byte("<ListBucketResult><Contents><Key>foo</Key><LastModified>2015-10-21T07:28:00.000Z</LastModified><Size>3</Size></Contents></ListBucketResult>"))
+		}
+	})
+
+	o, err := store.Stat("foo")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	statTime, _ := o.GetLastModified()
+
+	it, err := store.List("", ps.WithListMode(types.ListModePrefix))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	o, err = it.Next()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	listTime, _ := o.GetLastModified()
+
+	for name, v := range map[string]time.Time{"stat": statTime, "list": listTime} {
+		// Compare with == to make sure the location is the same as well.
+		if v != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, v)
+		}
+	}
+}
+
+func TestReadNegativeOffset(t *testing.T) {
+	var ranges []string
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		_, _ = w.Write([]byte("footer"))
+	})
+
+	var buf strings.Builder
+	_, err := store.Read("data.parquet", &buf, ps.WithOffset(-6))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=-6" {
+		t.Errorf("expected %s, got %v", "bytes=-6", ranges)
+	}
+
+	// Negative offset can't be used with size.
+	_, err = store.Read("data.parquet", &buf, ps.WithOffset(-6), ps.WithSize(3))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected %s, got %v", services.ErrCapabilityInsufficient, err)
+	}
+}
+
+func TestNewServicerAppendUserAgent(t *testing.T) {
+	var ua string
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		ua = r.Header.Get("User-Agent")
+	},
+		WithAppendUserAgent("my-app/1.0"),
+	)
+
+	_, _ = store.Exists("foo")
+	if !strings.HasPrefix(ua, "aliyun-sdk-go/") || !strings.HasSuffix(ua, " my-app/1.0") {
+		t.Errorf("expected %s appended to the default user agent, got %s", "my-app/1.0", ua)
+	}
+}
+
+func TestCheckReferer(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		hasErr bool
+	}{
+		{"empty", "", true},
+		{"domain", "example.com", false},
+		{"http", "http://www.example.com", false},
+		{"https wildcard", "https://*.example.com", false},
+		{"any scheme", "*://example.com", false},
+		{"question mark", "http://www.example.co?", false},
+		{"whitespace", "http://www.example.com ", true},
+		{"control character", "http://www.example.com\n", true},
+		{"unsupported scheme", "ftp://example.com", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReferer(tt.input)
+			if tt.hasErr {
+				if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+					t.Errorf("expected ErrRestrictionDissatisfied, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("check referer: %v", err)
+			}
+		})
+	}
+}
+
+func TestWebsiteConfigRoundTrip(t *testing.T) {
+	cfg := WebsiteConfig{
+		IndexDocument: "index.html",
+		ErrorDocument: "error.html",
+		RoutingRules: []WebsiteRoutingRule{
+			{
+				RuleNumber: 1,
+				Condition: WebsiteCondition{
+					KeyPrefixEquals: "docs/",
+					IncludeHeaders:  map[string]string{"host": "example.com", "x-a": "b"},
+				},
+				Redirect: WebsiteRedirect{
+					Type:                 WebsiteRedirectExternal,
+					PassQueryString:      true,
+					Protocol:             "https",
+					HostName:             "docs.example.com",
+					ReplaceKeyPrefixWith: "v2/",
+					HTTPRedirectCode:     301,
+				},
+			},
+			{
+				RuleNumber: 2,
+				Condition:  WebsiteCondition{HTTPErrorCodeReturnedEquals: 404},
+				Redirect: WebsiteRedirect{
+					Type:      WebsiteRedirectMirror,
+					MirrorURL: "https://origin.example.com/",
+				},
+			},
+		},
+	}
+
+	output := formatWebsiteConfig(cfg)
+	if n := len(output.RoutingRules[0].Condition.IncludeHeader); n != 2 {
+		t.Fatalf("expected 2 include headers, got %d", n)
+	}
+	if k := output.RoutingRules[0].Condition.IncludeHeader[0].Key; k != "host" {
+		t.Errorf("expected include headers sorted, got %s first", k)
+	}
+	if output.RoutingRules[1].Redirect.PassQueryString != nil {
+		t.Errorf("expected PassQueryString to be omitted")
+	}
+
+	got := parseWebsiteConfig(output)
+	if !reflect.DeepEqual(*got, cfg) {
+		t.Errorf("expected %+v, got %+v", cfg, *got)
+	}
+}
+
+func TestUpdateMeta(t *testing.T) {
+	var put http.Header
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Content-Length", "1024")
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Oss-Storage-Class", "IA")
+			w.Header().Set("X-Oss-Meta-Owner", "alice")
+			w.Header().Set("X-Oss-Server-Side-Encryption", ServerSideEncryptionKMS)
+			w.Header().Set("X-Oss-Server-Side-Encryption-Key-Id", "kms-key")
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`<AccessControlPolicy><AccessControlList><Grant>public-read</Grant></AccessControlList></AccessControlPolicy>`))
+		case http.MethodPut:
+			put = r.Header.Clone()
+			_, _ = w.Write([]byte(`<CopyObjectResult><ETag>"abc"</ETag></CopyObjectResult>`))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	err := store.UpdateMeta("index.html", ps.WithContentType("text/html"))
+	if err != nil {
+		t.Fatalf("update meta: %v", err)
+	}
+	cases := map[string]string{
+		"X-Oss-Metadata-Directive": "REPLACE",
+		"X-Oss-Copy-Source":        "/test-bucket/index.html",
+		"Content-Type":             "text/html",
+		"Cache-Control":            "no-cache",
+		"X-Oss-Storage-Class":      "IA",
+		"X-Oss-Meta-Owner":         "alice",
+		"X-Oss-Object-Acl":         "public-read",
+
+		"X-Oss-Server-Side-Encryption":        ServerSideEncryptionKMS,
+		"X-Oss-Server-Side-Encryption-Key-Id": "kms-key",
+	}
+	for k, v := range cases {
+		if got := put.Get(k); got != v {
+			t.Errorf("%s: expected %s, got %s", k, v, got)
+		}
+	}
+
+	// user_metadata replaces all user metadata.
+	err = store.UpdateMeta("index.html", WithUserMetadata(map[string]string{"team": "web"}))
+	if err != nil {
+		t.Fatalf("update meta: %v", err)
+	}
+	if got := put.Get("X-Oss-Meta-Owner"); got != "" {
+		t.Errorf("expected user metadata to be replaced, got owner %s", got)
+	}
+	if got := put.Get("X-Oss-Meta-Team"); got != "web" {
+		t.Errorf("expected team %s, got %s", "web", got)
+	}
+}
+
+func TestUpdateMetaServerSideEncryptionCustomer(t *testing.T) {
+	key := []byte(strings.Repeat("k", serverSideEncryptionCustomerKeySize))
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+
+	var head, put http.Header
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			head = r.Header.Clone()
+			w.Header().Set("Content-Length", "1024")
+			w.Header().Set(oss.HTTPHeaderSSECAlgorithm, ServerSideEncryptionAES256)
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`<AccessControlPolicy><AccessControlList><Grant>default</Grant></AccessControlList></AccessControlPolicy>`))
+		case http.MethodPut:
+			put = r.Header.Clone()
+			_, _ = w.Write([]byte(`<CopyObjectResult><ETag>"abc"</ETag></CopyObjectResult>`))
+		}
+	})
+
+	err := store.UpdateMeta("secret", ps.WithContentType("text/html"), WithServerSideEncryptionCustomerKey(key))
+	if err != nil {
+		t.Fatalf("update meta: %v", err)
+	}
+	if got := head.Get(oss.HTTPHeaderSSECKey); got != encodedKey {
+		t.Errorf("head: expected key %s, got %s", encodedKey, got)
+	}
+	for _, k := range []string{oss.HTTPHeaderSSECKey, copySourceSSECKeyHeader} {
+		if got := put.Get(k); got != encodedKey {
+			t.Errorf("%s: expected %s, got %s", k, encodedKey, got)
+		}
+	}
+	if got := put.Get(oss.HTTPHeaderOssObjectACL); got != "" {
+		t.Errorf("expected no acl, got %s", got)
+	}
+
+	err = store.UpdateMeta("secret", WithServerSideEncryptionCustomerKey(key[:16]))
+	if err == nil {
+		t.Errorf("expected error for invalid key")
+	}
+}
+
+func TestUpdateMetaMultipart(t *testing.T) {
+	var (
+		partFailed         bool
+		part, acl          http.Header
+		aborted, completed int32
+	)
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", strconv.FormatInt(copySizeMaximum+1, 10))
+			w.Header().Set("ETag", `"src"`)
+			w.Header().Set("X-Oss-Server-Side-Encryption", ServerSideEncryptionAES256)
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`<AccessControlPolicy><AccessControlList><Grant>private</Grant></AccessControlList></AccessControlPolicy>`))
+		case r.Method == http.MethodPost && q.Get("uploadId") == "":
+			_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>test-bucket</Bucket><Key>big</Key><UploadId>u1</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPut && q.Get("partNumber") != "":
+			part = r.Header.Clone()
+			if partFailed {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				_, _ = w.Write([]byte(`<Error><Code>PreconditionFailed</Code></Error>`))
+				return
+			}
+			_, _ = w.Write([]byte(`<CopyPartResult><ETag>"p1"</ETag></CopyPartResult>`))
+		case r.Method == http.MethodPost:
+			atomic.AddInt32(&completed, 1)
+			_, _ = w.Write([]byte(`<CompleteMultipartUploadResult><ETag>"abc"</ETag></CompleteMultipartUploadResult>`))
+		case r.Method == http.MethodDelete:
+			atomic.AddInt32(&aborted, 1)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut:
+			if _, ok := q["acl"]; ok {
+				acl = r.Header.Clone()
+				return
+			}
+			t.Errorf("unexpected copy object")
+		}
+	})
+
+	err := store.UpdateMeta("big", ps.WithContentType("text/html"))
+	if err != nil {
+		t.Fatalf("update meta: %v", err)
+	}
+	if got := part.Get(oss.HTTPHeaderOssCopySourceIfMatch); got != `"src"` {
+		t.Errorf("expected copy source if match %s, got %s", `"src"`, got)
+	}
+	if got := acl.Get(oss.HTTPHeaderOssObjectACL); got != ObjectACLPrivate {
+		t.Errorf("expected acl %s, got %s", ObjectACLPrivate, got)
+	}
+	if atomic.LoadInt32(&completed) != 1 {
+		t.Errorf("expected multipart upload to be completed")
+	}
+
+	partFailed = true
+	err = store.UpdateMeta("big", ps.WithContentType("text/html"))
+	if err == nil || !strings.Contains(err.Error(), "object is kept unchanged") {
+		t.Errorf("expected kept unchanged error, got %v", err)
+	}
+	if atomic.LoadInt32(&aborted) != 1 {
+		t.Errorf("expected multipart upload to be aborted")
+	}
+}
+
+// newMultipartTestStorage will create a storage which accepts multipart uploads,
+// sizes of the uploaded parts are recorded by part number.
+func newMultipartTestStorage(t *testing.T, failPart string) (store *Storage, sizes map[string]int, completed, aborted *int32) {
+	var mu sync.Mutex
+	sizes = make(map[string]int)
+	completed, aborted = new(int32), new(int32)
+	store = newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && q.Get("uploadId") == "":
+			_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>test-bucket</Bucket><Key>big</Key><UploadId>u1</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPut:
+			data, _ := ioutil.ReadAll(r.Body)
+			if q.Get("partNumber") == failPart {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			mu.Lock()
+			sizes[q.Get("partNumber")] = len(data)
+			mu.Unlock()
+			w.Header().Set("ETag", `"p`+q.Get("partNumber")+`"`)
+		case r.Method == http.MethodPost:
+			atomic.AddInt32(completed, 1)
+			_, _ = w.Write([]byte(`<CompleteMultipartUploadResult><ETag>"abc"</ETag></CompleteMultipartUploadResult>`))
+		case r.Method == http.MethodDelete:
+			atomic.AddInt32(aborted, 1)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	return
+}
+
+func TestUploadMultipart(t *testing.T) {
+	content := strings.Repeat("x", 2*multipartSizeMinimum+1)
+	expected := map[string]int{"1": multipartSizeMinimum, "2": multipartSizeMinimum, "3": 1}
+
+	// Plain readers are buffered.
+	store, sizes, completed, _ := newMultipartTestStorage(t, "")
+	n, err := store.UploadMultipart("big", struct{ io.Reader }{strings.NewReader(content)}, multipartSizeMinimum, 2)
+	if err != nil {
+		t.Fatalf("upload multipart: %v", err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("expected %d, got %d", len(content), n)
+	}
+	if !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("expected %v, got %v", expected, sizes)
+	}
+	if atomic.LoadInt32(completed) != 1 {
+		t.Errorf("expected multipart upload to be completed")
+	}
+
+	// Files are read via section readers from the current offset.
+	dir, err := ioutil.TempDir("", "oss")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := os.Create(filepath.Join(dir, "big"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer f.Close()
+	_, err = f.WriteString("skipped" + content)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_, err = f.Seek(int64(len("skipped")), io.SeekStart)
+	if err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+
+	store, sizes, _, _ = newMultipartTestStorage(t, "")
+	n, err = store.UploadMultipart("big", f, multipartSizeMinimum, 2)
+	if err != nil {
+		t.Fatalf("upload multipart: %v", err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("expected %d, got %d", len(content), n)
+	}
+	if !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("expected %v, got %v", expected, sizes)
+	}
+}
+
+func TestUploadMultipartAbort(t *testing.T) {
+	content := strings.Repeat("x", 3*multipartSizeMinimum)
+
+	store, _, completed, aborted := newMultipartTestStorage(t, "2")
+	_, err := store.UploadMultipart("big", strings.NewReader(content), multipartSizeMinimum, 2)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if atomic.LoadInt32(aborted) != 1 {
+		t.Errorf("expected multipart upload to be aborted")
+	}
+	if atomic.LoadInt32(completed) != 0 {
+		t.Errorf("expected multipart upload not to be completed")
+	}
+
+	_, err = store.UploadMultipart("big", strings.NewReader(content), multipartSizeMinimum-1, 2)
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected restriction dissatisfied, got %v", err)
+	}
+}
+
+func TestWriteMultipartCopy(t *testing.T) {
+	key := []byte(strings.Repeat("k", serverSideEncryptionCustomerKeySize))
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+
+	var header http.Header
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		_, _ = w.Write([]byte(`<CopyPartResult><ETag>"p1"</ETag></CopyPartResult>`))
+	})
+
+	o := store.Create("dst", ps.WithMultipartID("u1"))
+	part, err := store.WriteMultipartCopy(o, "src", 0, 1024, 0, WithServerSideEncryptionCustomerKey(key))
+	if err != nil {
+		t.Fatalf("write multipart copy: %v", err)
+	}
+	if part.ETag != `"p1"` || part.Size != 1024 {
+		t.Errorf("unexpected part %+v", part)
+	}
+	cases := map[string]string{
+		"X-Oss-Copy-Source-Range":     "bytes=0-1023",
+		oss.HTTPHeaderSSECKey:         encodedKey,
+		copySourceSSECKeyHeader:       encodedKey,
+		copySourceSSECAlgorithmHeader: ServerSideEncryptionAES256,
+	}
+	for k, v := range cases {
+		if got := header.Get(k); got != v {
+			t.Errorf("%s: expected %s, got %s", k, v, got)
+		}
+	}
+
+	_, err = store.WriteMultipartCopy(o, "src", 0, 1024, 0, WithStorageClass(StorageClassIA))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected capability insufficient, got %v", err)
+	}
+}
+
+func TestObjectACL(t *testing.T) {
+	acl := ObjectACLPrivate
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["acl"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`<AccessControlPolicy><AccessControlList><Grant>` + acl + `</Grant></AccessControlList></AccessControlPolicy>`))
+		case http.MethodPut:
+			acl = r.Header.Get("X-Oss-Object-Acl")
+		}
+	})
+
+	err := store.SetObjectACL("logo.png", ObjectACLPublicRead)
+	if err != nil {
+		t.Fatalf("set object acl: %v", err)
+	}
+	got, err := store.GetObjectACL("logo.png")
+	if err != nil {
+		t.Fatalf("get object acl: %v", err)
+	}
+	if got != ObjectACLPublicRead {
+		t.Errorf("expected %s, got %s", ObjectACLPublicRead, got)
+	}
+
+	err = store.SetObjectACL("logo.png", "public")
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected %s, got %v", services.ErrRestrictionDissatisfied, err)
+	}
+}
+
+func TestReadAfterWrite(t *testing.T) {
+	misses := 0
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case len(q["symlink"]) > 0:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("<Error><Code>NotSymlink</Code></Error>"))
+		case len(q["acl"]) > 0:
+			_, _ = w.Write([]byte("<AccessControlPolicy><AccessControlList><Grant>default</Grant></AccessControlList></AccessControlPolicy>"))
+		case misses < 2:
+			// The object is not visible for the first two requests.
+			misses++
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Length", "3")
+			_, _ = w.Write([]byte("foo"))
+		}
+	})
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	_, err := store.Stat("foo", WithReadAfterWrite(policy))
+	if err != nil {
+		t.Errorf("stat: %v", err)
+	}
+
+	misses = 0
+	var buf strings.Builder
+	_, err = store.Read("foo", &buf, WithReadAfterWrite(policy))
+	if err != nil {
+		t.Errorf("read: %v", err)
+	}
+
+	// NotFound will not be retried without read_after_write.
+	misses = 0
+	_, err = store.Read("foo", &buf)
+	if !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected %s, got %v", services.ErrObjectNotExist, err)
+	}
+}
+
+func TestListParallel(t *testing.T) {
+	keys := []string{"a.txt", "x/1", "x/2", "y/1", "y/z/2", "z/1"}
+
+	type content struct {
+		Key string
+	}
+	type result struct {
+		XMLName        xml.Name  `xml:"ListBucketResult"`
+		Contents       []content `xml:"Contents"`
+		CommonPrefixes []string  `xml:"CommonPrefixes>Prefix"`
+		IsTruncated    bool
+		NextMarker     string
+	}
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		prefix, delimiter, marker := q.Get("prefix"), q.Get("delimiter"), q.Get("marker")
+		maxKeys, _ := strconv.Atoi(q.Get("max-keys"))
+
+		output := result{}
+		seen := make(map[string]bool)
+		for _, k := range keys {
+			if !strings.HasPrefix(k, prefix) || k <= marker {
+				continue
+			}
+			if len(output.Contents)+len(output.CommonPrefixes) >= maxKeys {
+				output.IsTruncated = true
+				break
+			}
+			if i := strings.Index(k[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+				p := k[:len(prefix)+i+1]
+				if !seen[p] && p > marker {
+					seen[p] = true
+					output.CommonPrefixes = append(output.CommonPrefixes, p)
+					output.NextMarker = p
+				}
+				continue
+			}
+			output.Contents = append(output.Contents, content{Key: k})
+			output.NextMarker = k
+		}
+		_ = xml.NewEncoder(w).Encode(output)
+	})
+
+	it, err := store.List("", WithConcurrency(2), WithListPageSize(1))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var paths []string
+	for {
+		o, err := it.Next()
+		if errors.Is(err, types.IterateDone) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		paths = append(paths, o.Path)
+	}
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, keys) {
+		t.Errorf("expected %v, got %v", keys, paths)
+	}
+
+	_, err = store.List("", WithConcurrency(2), ps.WithListMode(types.ListModeDir))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected %s, got %v", services.ErrCapabilityInsufficient, err)
+	}
+}
+
+func TestBucketNotExist(t *testing.T) {
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		// HEAD responses have no body.
+		if r.Method != http.MethodHead {
+			_, _ = w.Write([]byte("<Error><Code>NoSuchBucket</Code><BucketName>missing-bucket</BucketName></Error>"))
+		}
+	}, ps.WithName("missing-bucket"))
+
+	_, err := store.Stat("foo")
+	if !errors.Is(err, ErrBucketNotExist) {
+		t.Errorf("stat: expected %s, got %v", ErrBucketNotExist, err)
+	}
+	_, err = store.Exists("foo")
+	if !errors.Is(err, ErrBucketNotExist) {
+		t.Errorf("exists: expected %s, got %v", ErrBucketNotExist, err)
+	}
+}
+
+func TestCheckBucketName(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		hasErr bool
+	}{
+		{"valid", "test-bucket-01", false},
+		{"minimum length", "abc", false},
+		{"maximum length", strings.Repeat("a", 63), false},
+		{"too short", "ab", true},
+		{"too long", strings.Repeat("a", 64), true},
+		{"uppercase", "Test-Bucket", true},
+		{"underscore", "test_bucket", true},
+		{"dot", "test.bucket", true},
+		{"leading hyphen", "-test", true},
+		{"trailing hyphen", "test-", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBucketName(tt.input)
+			if tt.hasErr {
+				if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+					t.Errorf("expected ErrRestrictionDissatisfied, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("check bucket name: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+		hasErr   bool
+	}{
+		{"hostname", "https:oss-cn-hangzhou.aliyuncs.com", "https://oss-cn-hangzhou.aliyuncs.com", false},
+		{"dual-stack hostname", "https:cn-hangzhou.oss.aliyuncs.com", "https://cn-hangzhou.oss.aliyuncs.com", false},
+		{"ipv4", "http:127.0.0.1:9000", "http://127.0.0.1:9000", false},
+		{"ipv6", "http:[::1]:9000", "http://[::1]:9000", false},
+		{"ipv6 without port", "https:[2001:db8::1]", "https://[2001:db8::1]", false},
+		{"ipv6 with default port", "https:[2001:db8::1]:443", "https://[2001:db8::1]", false},
+		{"ipv6 with invalid port", "http:[::1]:port", "", true},
+		{"ipv4 in brackets", "http:[127.0.0.1]", "", true},
+		{"invalid ipv6", "http:[oss]", "", true},
+		{"unsupported protocol", "tcp:[::1]:9000", "", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			url, _, err := parseEndpoint(tt.input)
+			if tt.hasErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse endpoint: %v", err)
+			}
+			if url != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, url)
+			}
+		})
+	}
+}
+
+func TestCheckEndpointLocation(t *testing.T) {
+	cases := []struct {
+		name     string
+		endpoint string
+		location string
+		expected bool
+	}{
+		{"same region", "https://oss-cn-hangzhou.aliyuncs.com", "oss-cn-hangzhou", true},
+		{"internal", "https://oss-cn-hangzhou-internal.aliyuncs.com", "oss-cn-hangzhou", true},
+		{"dual-stack", "https://cn-hangzhou.oss.aliyuncs.com", "oss-cn-hangzhou", true},
+		{"different region", "https://oss-cn-hangzhou.aliyuncs.com", "oss-cn-beijing", false},
+		{"region prefix", "https://oss-cn-hangzhou-finance.aliyuncs.com", "oss-cn-hangzhou", false},
+		{"sub region", "https://oss-cn-hangzhou.aliyuncs.com", "oss-cn-hang", false},
+		{"cname", "https://static.example.com", "oss-cn-beijing", true},
+		{"ip", "http://127.0.0.1:9000", "oss-cn-beijing", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkEndpointLocation(tt.endpoint, tt.location); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestServiceCreateLocation(t *testing.T) {
+	srv, err := newServicer(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("https:oss-cn-hangzhou.aliyuncs.com"),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+
+	// The request will not be sent since the location doesn't match the endpoint.
+	_, err = srv.Create("test-bucket", ps.WithLocation("oss-cn-hangzhou-finance"))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected capability insufficient, got %v", err)
+	}
+}
+
+func TestFormatEndpoint(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		internal  string
+		dualStack string
+	}{
+		{
+			"public",
+			"https://oss-cn-hangzhou.aliyuncs.com",
+			"https://oss-cn-hangzhou-internal.aliyuncs.com",
+			"https://cn-hangzhou.oss.aliyuncs.com",
+		},
+		{
+			"internal",
+			"https://oss-cn-hangzhou-internal.aliyuncs.com",
+			"https://oss-cn-hangzhou-internal.aliyuncs.com",
+			"https://cn-hangzhou-internal.oss.aliyuncs.com",
+		},
+		{
+			"dual-stack",
+			"http://cn-hangzhou.oss.aliyuncs.com:8080",
+			"http://cn-hangzhou-internal.oss.aliyuncs.com:8080",
+			"http://cn-hangzhou.oss.aliyuncs.com:8080",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			internal, err := formatInternalEndpoint(tt.input)
+			if err != nil {
+				t.Fatalf("format internal endpoint: %v", err)
+			}
+			if internal != tt.internal {
+				t.Errorf("expected %s, got %s", tt.internal, internal)
+			}
+
+			dualStack, err := formatDualStackEndpoint(tt.input)
+			if err != nil {
+				t.Fatalf("format dual-stack endpoint: %v", err)
+			}
+			if dualStack != tt.dualStack {
+				t.Errorf("expected %s, got %s", tt.dualStack, dualStack)
+			}
+		})
+	}
+
+	_, err := formatDualStackEndpoint("http://127.0.0.1:9000")
+	if err == nil {
+		t.Errorf("expected error for non OSS endpoint")
+	}
+}
+
+func TestWriteSizeMismatch(t *testing.T) {
+	var requests int32
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = ioutil.ReadAll(r.Body)
+	})
+
+	_, err := store.Write("short", strings.NewReader("abc"), 5, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	if !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected %s, got %v", ErrSizeMismatch, err)
+	}
+	if n := atomic.LoadInt32(&requests); n > 1 {
+		t.Errorf("expected size mismatch not to be retried, got %d requests", n)
+	}
+
+	_, err = store.Write("exact", strings.NewReader("abc"), 3)
+	if err != nil {
+		t.Errorf("write: %v", err)
+	}
+}
+
+func TestGetBucketInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["bucketInfo"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`<BucketInfo><Bucket>
+<Name>test-bucket</Name>
+<Location>oss-cn-hangzhou</Location>
+<CreationDate>2015-10-21T07:28:00.000Z</CreationDate>
+<StorageClass>IA</StorageClass>
+<AccessControlList><Grant>private</Grant></AccessControlList>
+<Versioning>Enabled</Versioning>
+<DataRedundancyType>ZRS</DataRedundancyType>
+</Bucket></BucketInfo>`))
+	}))
+	defer srv.Close()
+
+	servicer, err := newServicer(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("http:"+srv.Listener.Addr().String()),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+
+	info, err := servicer.GetBucketInfo("test-bucket")
+	if err != nil {
+		t.Fatalf("get bucket info: %v", err)
+	}
+	expected := BucketInfo{
+		Name:           "test-bucket",
+		Location:       "oss-cn-hangzhou",
+		CreationDate:   time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+		StorageClass:   StorageClassIA,
+		ACL:            "private",
+		Versioning:     "Enabled",
+		RedundancyType: "ZRS",
+	}
+	if !reflect.DeepEqual(*info, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *info)
+	}
+}
+
+func TestServerSideEncryptionCustomer(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	keyMd5 := "mT2HRsMGJ5IX5C+0rreZ8Q=="
+
+	var header http.Header
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		_, _ = ioutil.ReadAll(r.Body)
+	})
+
+	check := func(op string) {
+		expected := map[string]string{
+			oss.HTTPHeaderSSECAlgorithm: ServerSideEncryptionAES256,
+			oss.HTTPHeaderSSECKey:       base64.StdEncoding.EncodeToString(key),
+			oss.HTTPHeaderSSECKeyMd5:    keyMd5,
+		}
+		for k, v := range expected {
+			if got := header.Get(k); got != v {
+				t.Errorf("%s: %s expected %s, got %s", op, k, v, got)
+			}
+		}
+	}
+
+	_, err := store.Write("secret", strings.NewReader("abc"), 3, WithServerSideEncryptionCustomerKey(key))
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	check("write")
+
+	var buf strings.Builder
+	_, err = store.Read("secret", &buf, WithServerSideEncryptionCustomerKey(key), WithServerSideEncryptionCustomerKeyMd5(keyMd5))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	check("read")
+
+	_, err = store.Write("secret", strings.NewReader("abc"), 3,
+		WithServerSideEncryptionCustomerKey(key), WithServerSideEncryptionCustomerKeyMd5("invalid"))
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected %s, got %v", services.ErrRestrictionDissatisfied, err)
+	}
+	_, err = store.Write("secret", strings.NewReader("abc"), 3, WithServerSideEncryptionCustomerKey(key[:16]))
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected %s, got %v", services.ErrRestrictionDissatisfied, err)
+	}
+	_, err = store.Write("secret", strings.NewReader("abc"), 3,
+		WithServerSideEncryptionCustomerKey(key), WithServerSideEncryption(ServerSideEncryptionKMS))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected %s, got %v", services.ErrCapabilityInsufficient, err)
+	}
+}
+
+func TestFormatErrorThrottled(t *testing.T) {
+	cases := []struct {
+		name  string
+		input error
+	}{
+		{"slow down", oss.ServiceError{Code: responseCodeSlowDown, StatusCode: 503}},
+		{"service unavailable", oss.ServiceError{Code: responseCodeServiceUnavailable, StatusCode: 503}},
+		{"head", oss.ServiceError{StatusCode: 503}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := formatError(tt.input)
+			if !errors.Is(err, services.ErrRequestThrottled) {
+				t.Errorf("expected %s, got %s", services.ErrRequestThrottled, err)
+			}
+		})
+	}
+}
+
+func TestReadRetryThrottled(t *testing.T) {
+	throttled := 0
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if throttled < 2 {
+			throttled++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("<Error><Code>SlowDown</Code></Error>"))
+			return
+		}
+		_, _ = w.Write([]byte("foo"))
+	})
+
+	var buf strings.Builder
+	_, err := store.Read("foo", &buf)
+	if !errors.Is(err, services.ErrRequestThrottled) {
+		t.Errorf("expected %s, got %v", services.ErrRequestThrottled, err)
+	}
+
+	throttled = 0
+	buf.Reset()
+	_, err = store.Read("foo", &buf, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != "foo" {
+		t.Errorf("expected %s, got %s", "foo", buf.String())
+	}
+}
+
+func TestQuerySignCanceledContext(t *testing.T) {
+	_, store, err := newServicerAndStorager(
+		ps.WithCredential("hmac:ak:sk"),
+		ps.WithEndpoint("https:oss-cn-hangzhou.aliyuncs.com"),
+		ps.WithName("test-bucket"),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = store.QuerySignHTTPReadWithContext(ctx, "test", time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("read: expected %s, got %v", context.Canceled, err)
+	}
+	_, err = store.QuerySignHTTPWriteWithContext(ctx, "test", 1, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("write: expected %s, got %v", context.Canceled, err)
+	}
+	_, err = store.QuerySignHTTPDeleteWithContext(ctx, "test", time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("delete: expected %s, got %v", context.Canceled, err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	var storageClass, forbidOverwrite string
+	var deletes int32
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Content-Length", "1")
+		case http.MethodPut:
+			storageClass = r.Header.Get("X-Oss-Storage-Class")
+			forbidOverwrite = r.Header.Get("X-Oss-Forbid-Overwrite")
+			_, _ = w.Write([]byte(`<CopyObjectResult><ETag>"abc"</ETag></CopyObjectResult>`))
+		case http.MethodDelete:
+			// The first delete fails with a server error, and the retried one is denied.
+			if atomic.AddInt32(&deletes, 1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("X-Oss-Request-Id", "req-id")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<Error><Code>AccessDenied</Code><RequestId>req-id</RequestId></Error>`))
+		}
+	})
+
+	err := store.Move("src", "dst",
+		WithStorageClass(StorageClassIA),
+		WithForbidOverwrite(),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+	)
+	if storageClass != StorageClassIA {
+		t.Errorf("expected storage class %s, got %s", StorageClassIA, storageClass)
+	}
+	if forbidOverwrite != "true" {
+		t.Errorf("expected forbid overwrite, got %q", forbidOverwrite)
+	}
+	if n := atomic.LoadInt32(&deletes); n != 2 {
+		t.Errorf("expected %d deletes, got %d", 2, n)
+	}
+	if !errors.Is(err, services.ErrPermissionDenied) {
+		t.Errorf("expected %s, got %v", services.ErrPermissionDenied, err)
+	}
+	var re ResponseError
+	if !errors.As(err, &re) || re.RequestID != "req-id" {
+		t.Errorf("expected request id in error, got %v", err)
+	}
+}
+
+func TestFormatServerSideEncryptionOptions(t *testing.T) {
+	key := []byte(strings.Repeat("k", serverSideEncryptionCustomerKeySize))
+
+	cases := []struct {
+		name    string
+		input   serverSideEncryptionPairs
+		options int
+		hasErr  bool
+	}{
+		{"empty", serverSideEncryptionPairs{}, 0, false},
+		{"kms with sm4", serverSideEncryptionPairs{
+			HasServerSideEncryption:      true,
+			ServerSideEncryption:         ServerSideEncryptionKMS,
+			HasServerSideDataEncryption:  true,
+			ServerSideDataEncryption:     ServerSideDataEncryptionSM4,
+			HasServerSideEncryptionKeyID: true,
+			ServerSideEncryptionKeyID:    "key-id",
+		}, 3, false},
+		{"sm4 without kms", serverSideEncryptionPairs{
+			HasServerSideEncryption:     true,
+			ServerSideEncryption:        ServerSideEncryptionAES256,
+			HasServerSideDataEncryption: true,
+			ServerSideDataEncryption:    ServerSideDataEncryptionSM4,
+		}, 0, true},
+		{"key id without kms", serverSideEncryptionPairs{
+			HasServerSideEncryptionKeyID: true,
+			ServerSideEncryptionKeyID:    "key-id",
+		}, 0, true},
+		{"customer key", serverSideEncryptionPairs{
+			HasServerSideEncryptionCustomerKey: true,
+			ServerSideEncryptionCustomerKey:    key,
+		}, 3, false},
+		{"customer key with kms", serverSideEncryptionPairs{
+			HasServerSideEncryption:            true,
+			ServerSideEncryption:               ServerSideEncryptionKMS,
+			HasServerSideEncryptionCustomerKey: true,
+			ServerSideEncryptionCustomerKey:    key,
+		}, 0, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := formatServerSideEncryptionOptions(tt.input)
+			if tt.hasErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.hasErr, err)
+			}
+			if len(options) != tt.options {
+				t.Errorf("expected %d options, got %d", tt.options, len(options))
+			}
+		})
+	}
+}
+
+func TestStatObjectACL(t *testing.T) {
+	var aclRequests int32
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case len(q["symlink"]) > 0:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("<Error><Code>NotSymlink</Code></Error>"))
+		case len(q["acl"]) > 0:
+			atomic.AddInt32(&aclRequests, 1)
+			_, _ = w.Write([]byte("<AccessControlPolicy><AccessControlList><Grant>private</Grant></AccessControlList></AccessControlPolicy>"))
+		default:
+			w.Header().Set("Content-Length", "3")
+		}
+	})
+
+	// The ACL should not be requested by default, so that Stat works without
+	// the oss:GetObjectAcl permission.
+	o, err := store.Stat("foo")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if n := atomic.LoadInt32(&aclRequests); n != 0 {
+		t.Errorf("expected no acl request, got %d", n)
+	}
+	if acl := GetObjectSystemMetadata(o).ObjectACL; acl != "" {
+		t.Errorf("expected empty acl, got %s", acl)
+	}
+
+	o, err = store.Stat("foo", WithStatObjectACL())
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if acl := GetObjectSystemMetadata(o).ObjectACL; acl != ObjectACLPrivate {
+		t.Errorf("expected %s, got %s", ObjectACLPrivate, acl)
+	}
+}
+
+func TestWriteStreamMultipart(t *testing.T) {
+	var storageClass string
+	var parts, attempts int32
+	var completed bool
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && q.Get("uploadId") != "":
+			completed = true
+			_, _ = w.Write([]byte(`<CompleteMultipartUploadResult><ETag>"abc"</ETag></CompleteMultipartUploadResult>`))
+		case r.Method == http.MethodPost:
+			storageClass = r.Header.Get("X-Oss-Storage-Class")
+			_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>test-bucket</Bucket><Key>stream</Key><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPut && q.Get("partNumber") != "":
+			// The first attempt of every part fails, and will be retried.
+			if atomic.AddInt32(&attempts, 1)%2 == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			atomic.AddInt32(&parts, 1)
+			w.Header().Set("ETag", `"part"`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	var read int64
+	size := int64(resumablePartSizeDefault + 1)
+	n, err := store.Write("stream", strings.NewReader(strings.Repeat("a", int(size))), -1,
+		WithStorageClass(StorageClassIA),
+		ps.WithIoCallback(func(bs []byte) { read += int64(len(bs)) }),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+	)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n != size || read != size {
+		t.Errorf("expected %d bytes written and read, got %d and %d", size, n, read)
+	}
+	if p := atomic.LoadInt32(&parts); p != 2 || !completed {
+		t.Errorf("expected 2 parts completed, got %d parts, completed %v", p, completed)
+	}
+	if storageClass != StorageClassIA {
+		t.Errorf("expected storage class %s, got %s", StorageClassIA, storageClass)
+	}
+}
+
+func TestWriteCrc64(t *testing.T) {
+	crc := crc64.Checksum([]byte("abc"), crc64.MakeTable(crc64.ECMA))
+	remote := crc
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set(oss.HTTPHeaderOssCRC64, strconv.FormatUint(remote, 10))
+	})
+
+	_, err := store.Write("abc", strings.NewReader("abc"), 3, WithEnableCrc64Check())
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	remote = crc + 1
+	_, err = store.Write("abc", strings.NewReader("abc"), 3, WithEnableCrc64Check())
+	if !errors.Is(err, ErrCrc64Mismatch) {
+		t.Errorf("expected crc64 mismatch, got %v", err)
+	}
+	_, err = store.Write("abc", strings.NewReader("abc"), -1, WithEnableCrc64Check())
+	if !errors.Is(err, ErrCrc64Mismatch) {
+		t.Errorf("expected crc64 mismatch for stream, got %v", err)
+	}
+}
+
+func TestWriteStreamUnsupportedPairs(t *testing.T) {
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	})
+
+	size := resumablePartSizeDefault + 1
+	_, err := store.Write("stream", strings.NewReader(strings.Repeat("a", size)), -1,
+		WithCacheControl("no-cache"),
+		WithExpires(time.Now()),
+		WithUserMetadata(map[string]string{"a": "b"}),
+	)
+	var pe services.PairUnsupportedError
+	if !errors.As(err, &pe) || pe.Pair.Key != "cache_control" {
+		t.Fatalf("expected cache_control unsupported, got %v", err)
+	}
+	for _, k := range []string{"expires", "user_metadata"} {
+		if !strings.Contains(err.Error(), k) {
+			t.Errorf("expected %s to be reported, got %v", k, err)
+		}
+	}
+}
+
+func TestListParallelAbandoned(t *testing.T) {
+	// The listing never ends, so the workers can only be stopped by canceling.
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("marker") + "a"
+		_, _ = w.Write([]byte(`<ListBucketResult><Contents><Key>` + key + `</Key></Contents>` +
+			`<IsTruncated>true</IsTruncated><NextMarker>` + key + `</NextMarker></ListBucketResult>`))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	input := &parallelObjectPageStatus{maxKeys: 1, concurrency: 2}
+	err := store.nextObjectPageByPrefixParallel(ctx, &types.ObjectPage{Status: input})
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	// Abandon the iteration, pages should be closed once the context is canceled.
+	cancel()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-input.pages:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("workers are not stopped after the context is canceled")
+		}
+	}
+}
+
+func TestListDirSkipMarker(t *testing.T) {
+	// The first page only contains the marker of the listed dir itself.
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("marker") == "" {
+			_, _ = w.Write([]byte(`<ListBucketResult><Contents><Key>dir/</Key></Contents>` +
+				`<IsTruncated>true</IsTruncated><NextMarker>dir/</NextMarker></ListBucketResult>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<ListBucketResult><Contents><Key>dir/a</Key></Contents>` +
+			`<IsTruncated>false</IsTruncated></ListBucketResult>`))
+	})
+
+	it, err := store.List("dir/", ps.WithListMode(types.ListModeDir), WithListPageSize(1))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var paths []string
+	for {
+		o, err := it.Next()
+		if errors.Is(err, types.IterateDone) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		paths = append(paths, o.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"dir/a"}) {
+		t.Errorf("expected %v, got %v", []string{"dir/a"}, paths)
+	}
+}
+
+func TestSelectObjectRecordDelimiter(t *testing.T) {
+	var body string
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		body = string(bs)
+	})
+
+	rc, err := store.SelectObject("a.json", "select * from ossobject",
+		WithSelectJSONType("LINES"), WithSelectJSONRecordDelimiter(";"))
+	if err != nil {
+		t.Fatalf("select object: %v", err)
+	}
+	_ = rc.Close()
+	// OSS SDK encodes the delimiter with base64.
+	if !strings.Contains(body, "<RecordDelimiter>"+base64.StdEncoding.EncodeToString([]byte(";"))+"</RecordDelimiter>") {
+		t.Errorf("expected json record delimiter in request, got %s", body)
+	}
+
+	_, err = store.SelectObject("a.json", "select * from ossobject",
+		WithSelectJSONType("LINES"), WithSelectCsvRecordDelimiter(";"))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected %s, got %v", services.ErrCapabilityInsufficient, err)
+	}
+	_, err = store.SelectObject("a.csv", "select * from ossobject", WithSelectJSONRecordDelimiter(";"))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected %s, got %v", services.ErrCapabilityInsufficient, err)
+	}
+}
+
+func TestCheckBucketExistAccessDenied(t *testing.T) {
+	var bucketInfos int32
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["bucketInfo"]; ok {
+			atomic.AddInt32(&bucketInfos, 1)
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for i := 0; i < 3; i++ {
+		exist, err := store.Exists("foo")
+		if err != nil || exist {
+			t.Errorf("expected not exist, got %v, %v", exist, err)
+		}
+	}
+	if n := atomic.LoadInt32(&bucketInfos); n != 1 {
+		t.Errorf("expected bucket to be checked once, got %d", n)
+	}
+}
+
+func TestNewServicerCredentialRequired(t *testing.T) {
+	// ECS instance metadata should not be requested without ecs_ram_role.
+	_, err := newServicer(ps.WithEndpoint("http:127.0.0.1:1"))
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected %s, got %v", services.ErrRestrictionDissatisfied, err)
+	}
+
+	_, err = newServicer(
+		ps.WithCredential("ecs_ram_role:role"),
+		ps.WithEndpoint("http:127.0.0.1:1"),
+		WithSecurityToken("token"),
+	)
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected %s, got %v", services.ErrCapabilityInsufficient, err)
+	}
+}
+
+func TestECSRAMRoleProviderRefresh(t *testing.T) {
+	var fetches int32
+	refreshing := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			close(refreshing)
+		}
+		<-release
+		_, _ = w.Write([]byte(`{"Code":"Success","AccessKeyId":"new","AccessKeySecret":"sk","Expiration":"` +
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`))
+	}))
+	defer srv.Close()
+
+	p := &ecsRAMRoleProvider{
+		client:   srv.Client(),
+		endpoint: srv.URL + "/",
+		roleName: "role",
+		creds:    &ecsRAMRoleCredentials{AccessKeyID: "old", Expiration: time.Now().Add(time.Minute)},
+	}
+
+	done := make(chan oss.Credentials)
+	go func() {
+		done <- p.GetCredentials()
+	}()
+	<-refreshing
+
+	// Other callers should not be blocked by the refresh.
+	if v := p.GetCredentials().GetAccessKeyID(); v != "old" {
+		t.Errorf("expected %s during refresh, got %s", "old", v)
+	}
+	close(release)
+
+	if v := (<-done).GetAccessKeyID(); v != "new" {
+		t.Errorf("expected %s, got %s", "new", v)
+	}
+	if v := p.GetCredentials().GetAccessKeyID(); v != "new" {
+		t.Errorf("expected %s after refresh, got %s", "new", v)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected %d fetch, got %d", 1, n)
+	}
+}
+
+func TestDecodeUserMetadataValue(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"ascii", "hello world", "hello world"},
+		{"q encoding", "=?utf-8?q?hello?=", "=?utf-8?q?hello?="},
+		{"other charset", "=?iso-8859-1?b?aGVsbG8=?=", "=?iso-8859-1?b?aGVsbG8=?="},
+		{"mixed", "hello =?utf-8?b?5L2g5aW9?=", "hello =?utf-8?b?5L2g5aW9?="},
+		{"encoded", encodeUserMetadataValue("你好"), "你好"},
+		{"long encoded", encodeUserMetadataValue(strings.Repeat("你好", 50)), strings.Repeat("你好", 50)},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := decodeUserMetadataValue(tt.value); v != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	var body string
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["restore"]; !ok || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/restoring") {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`<Error><Code>RestoreAlreadyInProgress</Code></Error>`))
+			return
+		}
+		bs, _ := ioutil.ReadAll(r.Body)
+		body = string(bs)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	err := store.Restore("archived", WithRestoreDays(3), WithRestoreTier("Expedited"))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	var config oss.RestoreConfiguration
+	if err := xml.Unmarshal([]byte(body), &config); err != nil {
+		t.Fatalf("unmarshal restore config %s: %v", body, err)
+	}
+	if config.Days != 3 || config.Tier != "Expedited" {
+		t.Errorf("expected 3 days and Expedited tier, got %d days and %s tier", config.Days, config.Tier)
+	}
+
+	err = store.Restore("restoring")
+	if !errors.Is(err, ErrRestoreAlreadyInProgress) {
+		t.Errorf("expected %s, got %v", ErrRestoreAlreadyInProgress, err)
+	}
+}
+
+func TestReadFileTempDir(t *testing.T) {
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	})
+
+	dir, err := ioutil.TempDir("", "oss-read-file")
+	if err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tempDir, err := ioutil.TempDir("", "oss-read-file-temp")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	filePath := filepath.Join(dir, "object")
+	n, err := store.ReadFile("object", filePath, WithTempDir(tempDir))
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("read local file: %v", err)
+	}
+	if n != int64(len("content")) || string(content) != "content" {
+		t.Errorf("expected %s, got %d bytes %s", "content", n, content)
+	}
+	if fis, _ := ioutil.ReadDir(tempDir); len(fis) != 0 {
+		t.Errorf("expected temp dir to be empty, got %d files", len(fis))
+	}
+}
+
+func TestCopyFileAtomic(t *testing.T) {
+	// os.Rename can't be tested across file systems here, test the fallback directly.
+	srcDir, err := ioutil.TempDir("", "oss-copy-src")
+	if err != nil {
+		t.Fatalf("create src dir: %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+	dstDir, err := ioutil.TempDir("", "oss-copy-dst")
+	if err != nil {
+		t.Fatalf("create dst dir: %v", err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	src, dst := filepath.Join(srcDir, "src"), filepath.Join(dstDir, "dst")
+	if err := ioutil.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatalf("write src: %v", err)
+	}
+	if err := ioutil.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatalf("write dst: %v", err)
+	}
+
+	if err := copyFileAtomic(src, dst); err != nil {
+		t.Fatalf("copy file: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(dst); string(content) != "new" {
+		t.Errorf("expected %s, got %s", "new", content)
+	}
+	if fis, _ := ioutil.ReadDir(dstDir); len(fis) != 1 {
+		t.Errorf("expected no temporary file left, got %d files", len(fis))
+	}
+}
+
+func TestBatchDelete(t *testing.T) {
+	type object struct {
+		Key string
+	}
+	type deleteRequest struct {
+		Objects []object `xml:"Object"`
+	}
+	type deleteResult struct {
+		XMLName xml.Name `xml:"DeleteResult"`
+		Deleted []object `xml:"Deleted"`
+	}
+	var batches []int
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		var req deleteRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, len(req.Objects))
+
+		// Objects named failed are not deleted.
+		output := deleteResult{}
+		for _, v := range req.Objects {
+			if !strings.HasSuffix(v.Key, "failed") {
+				output.Deleted = append(output.Deleted, v)
+			}
+		}
+		_ = xml.NewEncoder(w).Encode(output)
+	})
+
+	paths := []string{"failed", "../escaped"}
+	for i := len(paths); i < deleteObjectsMaximum+2; i++ {
+		paths = append(paths, strconv.Itoa(i))
+	}
+	result, err := store.BatchDelete(paths)
+	if err != nil {
+		t.Fatalf("batch delete: %v", err)
+	}
+	if !reflect.DeepEqual(batches, []int{deleteObjectsMaximum - 1, 2}) {
+		t.Errorf("expected batches %v, got %v", []int{deleteObjectsMaximum - 1, 2}, batches)
+	}
+	if len(result.Deleted) != len(paths)-2 {
+		t.Errorf("expected %d deleted, got %d", len(paths)-2, len(result.Deleted))
+	}
+	if len(result.Failed) != 2 || result.Failed["failed"] == nil {
+		t.Errorf("expected failed and ../escaped to fail, got %v", result.Failed)
+	}
+	if !errors.Is(result.Failed["../escaped"], services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected restriction dissatisfied, got %v", result.Failed["../escaped"])
+	}
+}
+
+func TestMultipartValidation(t *testing.T) {
+	var requests int32
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`<CompleteMultipartUploadResult><ETag>"abc"</ETag></CompleteMultipartUploadResult>`))
+	})
+	o := store.Create("big", ps.WithMultipartID("u1"))
+
+	cases := []struct {
+		name string
+		fn   func() error
+	}{
+		{"negative index", func() error {
+			_, _, err := store.WriteMultipart(o, strings.NewReader(""), 0, -1)
+			return err
+		}},
+		{"index too large", func() error {
+			_, _, err := store.WriteMultipart(o, strings.NewReader(""), 0, multipartNumberMaximum)
+			return err
+		}},
+		{"part too large", func() error {
+			_, _, err := store.WriteMultipart(o, strings.NewReader(""), multipartSizeMaximum+1, 0)
+			return err
+		}},
+		{"too many parts", func() error {
+			return store.CompleteMultipart(o, make([]*types.Part, multipartNumberMaximum+1))
+		}},
+		{"part too small", func() error {
+			return store.CompleteMultipart(o, []*types.Part{
+				{Index: 0, Size: multipartSizeMinimum - 1},
+				{Index: 1, Size: multipartSizeMinimum},
+			})
+		}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, services.ErrRestrictionDissatisfied) {
+				t.Errorf("expected restriction dissatisfied, got %v", err)
+			}
+		})
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("expected no requests, got %d", n)
+	}
+
+	// The last part can be smaller than the minimum.
+	err := store.CompleteMultipart(o, []*types.Part{
+		{Index: 0, Size: multipartSizeMinimum},
+		{Index: 1, Size: 1},
+	})
+	if err != nil {
+		t.Errorf("complete multipart: %v", err)
+	}
+}
+
+func TestWriteCallback(t *testing.T) {
+	callback := base64.StdEncoding.EncodeToString([]byte(`{"callbackUrl":"https://example.com/cb","callbackBody":"bucket=${bucket}&uid=${x:uid}"}`))
+	callbackVar := base64.StdEncoding.EncodeToString([]byte(`{"x:uid":"42"}`))
+
+	var header http.Header
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		_, _ = ioutil.ReadAll(r.Body)
+		_, _ = w.Write([]byte(`{"Status":"OK"}`))
+	})
+
+	var result []byte
+	_, err := store.Write("abc", strings.NewReader("abc"), 3,
+		WithCallback(callback),
+		WithCallbackVar(callbackVar),
+		WithCallbackResult(func(bs []byte) { result = bs }),
+	)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := header.Get(oss.HTTPHeaderOssCallback); got != callback {
+		t.Errorf("expected callback %s, got %s", callback, got)
+	}
+	if got := header.Get(oss.HTTPHeaderOssCallbackVar); got != callbackVar {
+		t.Errorf("expected callback var %s, got %s", callbackVar, got)
+	}
+	if string(result) != `{"Status":"OK"}` {
+		t.Errorf("expected callback result %s, got %s", `{"Status":"OK"}`, result)
+	}
+}
+
+// slowReader will return one byte per read after delay.
+type slowReader struct {
+	delay time.Duration
+}
+
+func (r slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	p[0] = 'a'
+	return 1, nil
+}
+
+func TestTimeout(t *testing.T) {
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			_, _ = ioutil.ReadAll(r.Body)
+			return
+		}
+		// Send the content slowly, so that the read will time out while reading the body.
+		w.Header().Set("Content-Length", "10")
+		for i := 0; i < 10; i++ {
+			_, _ = w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	})
+
+	var buf bytes.Buffer
+	_, err := store.Read("abc", &buf, WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("read: expected deadline exceeded, got %v", err)
+	}
+
+	_, err = store.Write("abc", slowReader{delay: 20 * time.Millisecond}, 10, WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("write: expected deadline exceeded, got %v", err)
+	}
+
+	// The operation finished in time is not affected.
+	_, err = store.Write("abc", strings.NewReader("abc"), 3, WithTimeout(time.Second))
+	if err != nil {
+		t.Errorf("write: %v", err)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oss")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "big")
+	err = ioutil.WriteFile(filePath, []byte(strings.Repeat("x", 2*multipartSizeMinimum+1)), 0644)
+	if err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	store, sizes, completed, _ := newMultipartTestStorage(t, "")
+	checkpointDir := filepath.Join(dir, "checkpoint")
+	err = store.UploadFile("big", filePath,
+		WithPartSize(multipartSizeMinimum), WithConcurrency(2), WithCheckpointDir(checkpointDir))
+	if err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+	expected := map[string]int{"1": multipartSizeMinimum, "2": multipartSizeMinimum, "3": 1}
+	if !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("expected %v, got %v", expected, sizes)
+	}
+	if atomic.LoadInt32(completed) != 1 {
+		t.Errorf("expected multipart upload to be completed")
+	}
+	if _, err := os.Stat(checkpointDir); err != nil {
+		t.Errorf("expected checkpoint dir to be created: %v", err)
+	}
+
+	err = store.UploadFile("big", filePath, WithPartSize(multipartSizeMinimum-1))
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected restriction dissatisfied, got %v", err)
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	var ranges int32
+	var versionID atomic.Value
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+		versionID.Store(r.URL.Query().Get("versionId"))
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "", time.Unix(1600000000, 0), strings.NewReader(content))
+	})
+
+	dir, err := ioutil.TempDir("", "oss")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "big")
+
+	err = store.DownloadFile("big", filePath,
+		WithPartSize(300), WithConcurrency(2), WithCheckpointDir(filepath.Join(dir, "checkpoint")), WithVersionID("v1"))
+	if err != nil {
+		t.Fatalf("download file: %v", err)
+	}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("expected %d bytes of content, got %d", len(content), len(data))
+	}
+	if n := atomic.LoadInt32(&ranges); n != 4 {
+		t.Errorf("expected 4 ranged reads, got %d", n)
+	}
+	if v := versionID.Load(); v != "v1" {
+		t.Errorf("expected version id v1, got %v", v)
+	}
+
+	err = store.DownloadFile("big", filePath, WithPartSize(-1))
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected capability insufficient, got %v", err)
+	}
+}
+
+func TestVersionID(t *testing.T) {
+	var requests []string
+	var mu sync.Mutex
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		requests = append(requests, r.Method+" "+q.Get("versionId"))
+		mu.Unlock()
+
+		switch {
+		case len(q["symlink"]) > 0:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("<Error><Code>NotSymlink</Code></Error>"))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("X-Oss-Version-Id", q.Get("versionId"))
+			w.Header().Set("Content-Length", "3")
+			_, _ = w.Write([]byte("abc"))
+		}
+	})
+
+	o, err := store.Stat("foo", WithVersionID("v1"))
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if v := GetObjectSystemMetadata(o).VersionID; v != "v1" {
+		t.Errorf("expected version id v1, got %s", v)
+	}
+	var buf bytes.Buffer
+	_, err = store.Read("foo", &buf, WithVersionID("v2"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	err = store.Delete("foo", WithVersionID("v3"))
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	for _, expected := range []string{"HEAD v1", "GET v2", "DELETE v3"} {
+		found := false
+		for _, v := range requests {
+			found = found || v == expected
+		}
+		if !found {
+			t.Errorf("expected request %s, got %v", expected, requests)
+		}
+	}
+}
+
+func TestListVersions(t *testing.T) {
+	var markers []string
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if _, ok := q["versions"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		markers = append(markers, q.Get("key-marker")+"@"+q.Get("version-id-marker"))
+		if q.Get("key-marker") == "" {
+			_, _ = w.Write([]byte(`<ListVersionsResult>` +
+				`<Version><Key>a</Key><VersionId>v2</VersionId><IsLatest>true</IsLatest><Size>3</Size><LastModified>2021-01-01T00:00:00.000Z</LastModified></Version>` +
+				`<IsTruncated>true</IsTruncated><NextKeyMarker>a</NextKeyMarker><NextVersionIdMarker>v2</NextVersionIdMarker></ListVersionsResult>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<ListVersionsResult>` +
+			`<Version><Key>a</Key><VersionId>v1</VersionId><IsLatest>false</IsLatest><Size>2</Size><LastModified>2020-01-01T00:00:00.000Z</LastModified></Version>` +
+			`<DeleteMarker><Key>b</Key><VersionId>v3</VersionId><IsLatest>true</IsLatest><LastModified>2021-01-01T00:00:00.000Z</LastModified></DeleteMarker>` +
+			`<IsTruncated>false</IsTruncated></ListVersionsResult>`))
+	})
+
+	it, err := store.List("", ps.WithListMode(types.ListModePrefix), WithListVersions())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var got []string
+	for {
+		o, err := it.Next()
+		if errors.Is(err, types.IterateDone) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		sm := GetObjectSystemMetadata(o)
+		// Delete markers don't have content length.
+		size, _ := o.GetContentLength()
+		got = append(got, fmt.Sprintf("%s@%s latest=%v marker=%v size=%d",
+			o.Path, sm.VersionID, sm.IsLatest, sm.IsDeleteMarker, size))
+	}
+	expected := []string{
+		"a@v2 latest=true marker=false size=3",
+		"a@v1 latest=false marker=false size=2",
+		"b@v3 latest=true marker=true size=0",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if !reflect.DeepEqual(markers, []string{"@", "a@v2"}) {
+		t.Errorf("expected markers %v, got %v", []string{"@", "a@v2"}, markers)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	var body []byte
+	srv := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			body, _ = ioutil.ReadAll(r.Body)
+		case http.MethodGet:
+			if body == nil {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`<Error><Code>NoSuchLifecycle</Code></Error>`))
+				return
+			}
+			_, _ = w.Write(body)
+		}
+	})
+
+	// No rules have been set.
+	rules, err := srv.GetLifecycle("test-bucket")
+	if err != nil || rules != nil {
+		t.Fatalf("expected no rules, got %v, %v", rules, err)
+	}
+
+	expected := []LifecycleRule{
+		{
+			ID:             "logs",
+			Prefix:         "logs/",
+			Enabled:        true,
+			ExpirationDays: 30,
+			Transitions: []LifecycleTransition{
+				{Days: 7, StorageClass: StorageClassIA},
+				{Days: 14, StorageClass: StorageClassArchive},
+			},
+		},
+		{
+			ID:                       "uploads",
+			AbortMultipartUploadDays: 3,
+		},
+	}
+	err = srv.SetLifecycle("test-bucket", expected)
+	if err != nil {
+		t.Fatalf("set lifecycle: %v", err)
+	}
+	if !strings.Contains(string(body), "<Status>Disabled</Status>") {
+		t.Errorf("expected disabled rule, got %s", body)
+	}
+	rules, err = srv.GetLifecycle("test-bucket")
+	if err != nil {
+		t.Fatalf("get lifecycle: %v", err)
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("expected %+v, got %+v", expected, rules)
+	}
+
+	err = srv.SetLifecycle("test-bucket", []LifecycleRule{{ID: "invalid", ExpirationDays: -1}})
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected restriction dissatisfied, got %v", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	var policy []byte
+	var requests int32
+	srv := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.Method {
+		case http.MethodPut:
+			policy, _ = ioutil.ReadAll(r.Body)
+		case http.MethodGet:
+			if policy == nil {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`<Error><Code>NoSuchBucketPolicy</Code></Error>`))
+				return
+			}
+			_, _ = w.Write(policy)
+		case http.MethodDelete:
+			policy = nil
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	expected := `{"Version":"1","Statement":[{"Action":["oss:GetObject"],"Effect":"Allow","Principal":["*"],"Resource":["acs:oss:*:*:test-bucket/*"]}]}`
+	err := srv.SetPolicy("test-bucket", expected)
+	if err != nil {
+		t.Fatalf("set policy: %v", err)
+	}
+	got, err := srv.GetPolicy("test-bucket")
+	if err != nil {
+		t.Fatalf("get policy: %v", err)
+	}
+	if got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	err = srv.DeletePolicy("test-bucket")
+	if err != nil {
+		t.Fatalf("delete policy: %v", err)
+	}
+	got, err = srv.GetPolicy("test-bucket")
+	if err != nil || got != "" {
+		t.Errorf("expected no policy, got %q, %v", got, err)
+	}
+
+	// Invalid policy will be rejected before sending the request.
+	n := atomic.LoadInt32(&requests)
+	err = srv.SetPolicy("test-bucket", `{"Version":`)
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected restriction dissatisfied, got %v", err)
+	}
+	if atomic.LoadInt32(&requests) != n {
+		t.Errorf("expected no request for invalid policy")
+	}
+}
+
+func TestLogging(t *testing.T) {
+	locations := map[string]string{
+		"test-bucket": "oss-cn-hangzhou",
+		"logs-bucket": "oss-cn-hangzhou",
+		"far-bucket":  "oss-cn-beijing",
+	}
+	var logging []byte
+	srv := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		bucket := strings.Trim(r.URL.Path, "/")
+		switch {
+		case len(q["bucketInfo"]) > 0:
+			_, _ = w.Write([]byte(`<BucketInfo><Bucket><Name>` + bucket + `</Name><Location>` + locations[bucket] + `</Location></Bucket></BucketInfo>`))
+		case r.Method == http.MethodPut:
+			logging, _ = ioutil.ReadAll(r.Body)
+		case r.Method == http.MethodGet:
+			if logging == nil {
+				_, _ = w.Write([]byte(`<BucketLoggingStatus></BucketLoggingStatus>`))
+				return
+			}
+			_, _ = w.Write(logging)
+		case r.Method == http.MethodDelete:
+			logging = nil
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	cfg, err := srv.GetLogging("test-bucket")
+	if err != nil || cfg != nil {
+		t.Fatalf("expected logging disabled, got %v, %v", cfg, err)
+	}
+
+	expected := LoggingConfig{TargetBucket: "logs-bucket", TargetPrefix: "access/"}
+	err = srv.SetLogging("test-bucket", expected)
+	if err != nil {
+		t.Fatalf("set logging: %v", err)
+	}
+	cfg, err = srv.GetLogging("test-bucket")
+	if err != nil {
+		t.Fatalf("get logging: %v", err)
+	}
+	if cfg == nil || *cfg != expected {
+		t.Errorf("expected %+v, got %+v", expected, cfg)
+	}
+
+	// The target bucket must be in the same region.
+	err = srv.SetLogging("test-bucket", LoggingConfig{TargetBucket: "far-bucket"})
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected restriction dissatisfied, got %v", err)
+	}
+
+	err = srv.DeleteLogging("test-bucket")
+	if err != nil {
+		t.Fatalf("delete logging: %v", err)
+	}
+	cfg, err = srv.GetLogging("test-bucket")
+	if err != nil || cfg != nil {
+		t.Errorf("expected logging disabled, got %v, %v", cfg, err)
+	}
+}
+
+func TestReadObjectNotRestored(t *testing.T) {
+	store := newTestStorage(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<Error><Code>InvalidObjectState</Code><Message>The operation is not valid for the object's state</Message></Error>`))
+	})
+
+	var buf bytes.Buffer
+	_, err := store.Read("archived", &buf)
+	if !errors.Is(err, ErrObjectNotRestored) {
+		t.Errorf("expected %s, got %v", ErrObjectNotRestored, err)
+	}
+	// InvalidObjectState is returned with 403, but it's not a permission error.
+	if errors.Is(err, services.ErrPermissionDenied) {
+		t.Errorf("expected not permission denied, got %v", err)
+	}
+}